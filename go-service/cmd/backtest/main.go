@@ -0,0 +1,403 @@
+// Command backtest replays raw_messages over a historical range through
+// the aggregation engine and writes the resulting series as CSV or JSON.
+// Nothing is written to aggregated_sentiments.
+//
+//	backtest -start 2025-01-01T00:00:00Z -end 2025-01-08T00:00:00Z \
+//	         -resolution 1h -config new.json -compare old.json -format csv
+//
+// A run config is a JSON object with any of strategy, filters, decay,
+// sources, authors and weight_profile, as accepted by POST /aggregate,
+// plus weights_file naming a weights file to score with. Settings a
+// config leaves out come from the service's own configuration. With
+// -compare both configs run over the same range and each row holds both
+// scores side by side.
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/aggregate"
+	"github.com/cosmic-hash/CryptoPulse/pkg/coins"
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// runConfig overrides the service's aggregation settings for one run.
+type runConfig struct {
+	Strategy      string                   `json:"strategy"`
+	Filters       *aggregate.FilterConfig  `json:"filters"`
+	Decay         *aggregate.DecayConfig   `json:"decay"`
+	Sources       *aggregate.SourceWeights `json:"sources"`
+	Authors       *aggregate.AuthorConfig  `json:"authors"`
+	WeightProfile string                   `json:"weight_profile"`
+	WeightsFile   string                   `json:"weights_file"`
+}
+
+// point is one coin's result in one window of one run.
+type point struct {
+	Score   float64 `json:"score"`
+	Count   int     `json:"count"`
+	Profile string  `json:"profile"`
+}
+
+type key struct {
+	t    time.Time
+	coin string
+}
+
+func main() {
+	startStr := flag.String("start", "", "start of the range (RFC3339, required)")
+	endStr := flag.String("end", "", "end of the range (RFC3339, required)")
+	tokens := flag.String("tokens", "", "comma-separated coin codes; default every enabled coin")
+	resolution := flag.String("resolution", model.DefaultResolution, "resolution of the written series")
+	configPath := flag.String("config", "", "run config JSON; default the service's settings")
+	comparePath := flag.String("compare", "", "second run config JSON to diff against -config")
+	format := flag.String("format", "csv", "output format: csv or json")
+	outPath := flag.String("out", "-", "output file, or - for stdout")
+	chunk := flag.Duration("chunk", 24*time.Hour, "range replayed per step; bounds the raw messages held in memory")
+	flag.Parse()
+
+	start, err := time.Parse(time.RFC3339, *startStr)
+	if err != nil {
+		log.Fatalf("Bad -start: %v", err)
+	}
+	end, err := time.Parse(time.RFC3339, *endStr)
+	if err != nil {
+		log.Fatalf("Bad -end: %v", err)
+	}
+	if !start.Before(end) {
+		log.Fatal("-start must be before -end")
+	}
+	if *format != "csv" && *format != "json" {
+		log.Fatalf("Unknown -format %q", *format)
+	}
+	if _, err := model.LookupResolution(*resolution); err != nil {
+		log.Fatal(err)
+	}
+
+	// 1) Load the service's settings and the registries the engine needs
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+	settings, _, err := config.ResolveSettings(nil)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if settings.Database.URL == "" {
+		log.Fatal("DATABASE_URL is not set")
+	}
+	config.Current = settings
+	db.InitDB(settings.Database)
+	if err := loadEngineSettings(settings); err != nil {
+		log.Fatal(err)
+	}
+	runCoins, err := selectCoins(*tokens)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 2) Resolve the run configs
+	optsA, err := loadRunConfig(*configPath)
+	if err != nil {
+		log.Fatalf("%s: %v", *configPath, err)
+	}
+	optsA.Resolution = *resolution
+	var optsB *aggregate.Options
+	if *comparePath != "" {
+		o, err := loadRunConfig(*comparePath)
+		if err != nil {
+			log.Fatalf("%s: %v", *comparePath, err)
+		}
+		o.Resolution = *resolution
+		optsB = &o
+	}
+
+	// 3) Replay and write
+	var out io.Writer = os.Stdout
+	if *outPath != "-" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *outPath, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := newRowWriter(out, *format, optsB != nil)
+
+	if optsB == nil {
+		err = aggregate.Backtest(runCoins, start, end, optsA, *chunk, func(buckets []aggregate.Bucket) error {
+			for _, b := range buckets {
+				for _, c := range runCoins {
+					if err := w.single(b.Time, c.Code, pointOf(b, c.Code)); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	} else {
+		err = compare(runCoins, start, end, optsA, *optsB, *chunk, w)
+	}
+	if err != nil {
+		log.Fatalf("Backtest failed: %v", err)
+	}
+	if err := w.close(); err != nil {
+		log.Fatalf("Failed to write output: %v", err)
+	}
+}
+
+// loadEngineSettings installs the service's questions, coins, weights and
+// aggregation defaults, as the server does at startup.
+func loadEngineSettings(s config.Settings) error {
+	questions, err := db.FetchQuestions()
+	if err != nil {
+		return fmt.Errorf("load questions: %w", err)
+	}
+	config.SetQuestions(questions)
+	if err := coins.Refresh(); err != nil {
+		return fmt.Errorf("load coins: %w", err)
+	}
+	if _, err := aggregate.ReloadWeights(); err != nil {
+		return fmt.Errorf("load weights: %w", err)
+	}
+	if err := aggregate.LoadFilterConfig(s.Filters); err != nil {
+		return fmt.Errorf("filter settings: %w", err)
+	}
+	if err := aggregate.LoadDecayConfig(s.Decay); err != nil {
+		return fmt.Errorf("decay settings: %w", err)
+	}
+	if err := aggregate.LoadSourceWeights(s.Aggregation.SourceWeightsFile); err != nil {
+		return fmt.Errorf("source weights: %w", err)
+	}
+	if err := aggregate.LoadAuthorConfig(s.Authors); err != nil {
+		return fmt.Errorf("author settings: %w", err)
+	}
+	return nil
+}
+
+// selectCoins returns the coins named in tokens, or every enabled coin.
+func selectCoins(tokens string) ([]aggregate.Coin, error) {
+	var out []aggregate.Coin
+	if tokens == "" {
+		for _, c := range coins.Enabled() {
+			out = append(out, aggregate.Coin{ID: c.ID, Code: c.Code})
+		}
+		return out, nil
+	}
+	for _, code := range strings.Split(tokens, ",") {
+		c, ok := coins.ByCode(strings.TrimSpace(code))
+		if !ok {
+			return nil, fmt.Errorf("unknown coin %q", code)
+		}
+		out = append(out, aggregate.Coin{ID: c.ID, Code: c.Code})
+	}
+	return out, nil
+}
+
+// loadRunConfig reads and validates the run config at path; an empty
+// path means no overrides.
+func loadRunConfig(path string) (aggregate.Options, error) {
+	var opts aggregate.Options
+	if path == "" {
+		return opts, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return opts, err
+	}
+	var rc runConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rc); err != nil {
+		return opts, err
+	}
+	if _, err := model.LookupAggregator(rc.Strategy); err != nil {
+		return opts, err
+	}
+	if rc.Filters != nil {
+		if err := rc.Filters.Validate(); err != nil {
+			return opts, err
+		}
+	}
+	if rc.Decay != nil {
+		if err := rc.Decay.Validate(); err != nil {
+			return opts, err
+		}
+	}
+	if rc.Sources != nil {
+		if err := rc.Sources.Validate(); err != nil {
+			return opts, err
+		}
+	}
+	if rc.Authors != nil {
+		if err := rc.Authors.Validate(); err != nil {
+			return opts, err
+		}
+	}
+	opts = aggregate.Options{
+		Strategy: rc.Strategy,
+		Filters:  rc.Filters,
+		Decay:    rc.Decay,
+		Sources:  rc.Sources,
+		Authors:  rc.Authors,
+		Profile:  rc.WeightProfile,
+	}
+	profiles := model.Profiles()
+	if rc.WeightsFile != "" {
+		if profiles, err = aggregate.ReadWeightsFile(rc.WeightsFile); err != nil {
+			return opts, err
+		}
+		if err := profiles.Validate(config.QuestionMapping); err != nil {
+			return opts, fmt.Errorf("%s: %w", rc.WeightsFile, err)
+		}
+		opts.Weights = &profiles
+	}
+	if rc.WeightProfile != "" {
+		if _, ok := profiles.Profiles[rc.WeightProfile]; !ok {
+			return opts, fmt.Errorf("unknown weight profile %q", rc.WeightProfile)
+		}
+	}
+	return opts, nil
+}
+
+// compare replays the range under a, then under b, and writes the two
+// series joined by window and coin. It logs how far they drift apart.
+func compare(runCoins []aggregate.Coin, start, end time.Time, a, b aggregate.Options, chunk time.Duration, w *rowWriter) error {
+	first := map[key]point{}
+	err := aggregate.Backtest(runCoins, start, end, a, chunk, func(buckets []aggregate.Bucket) error {
+		for _, bk := range buckets {
+			for _, c := range runCoins {
+				first[key{bk.Time, c.Code}] = pointOf(bk, c.Code)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	var (
+		n              int
+		sumAbs, maxAbs float64
+	)
+	err = aggregate.Backtest(runCoins, start, end, b, chunk, func(buckets []aggregate.Bucket) error {
+		for _, bk := range buckets {
+			for _, c := range runCoins {
+				pa, pb := first[key{bk.Time, c.Code}], pointOf(bk, c.Code)
+				d := math.Abs(pb.Score - pa.Score)
+				n++
+				sumAbs += d
+				maxAbs = math.Max(maxAbs, d)
+				if err := w.pair(bk.Time, c.Code, pa, pb); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("compare: %w", err)
+	}
+	if n > 0 {
+		log.Printf("[Backtest] %d points, mean |diff| %.6f, max |diff| %.6f", n, sumAbs/float64(n), maxAbs)
+	}
+	return nil
+}
+
+func pointOf(b aggregate.Bucket, code string) point {
+	return point{Score: b.Coins[code], Count: b.Stats[code].Count, Profile: b.Profiles[code]}
+}
+
+// rowWriter writes result rows as CSV or as one JSON array.
+type rowWriter struct {
+	csv  *csv.Writer
+	json io.Writer
+	rows int
+}
+
+func newRowWriter(out io.Writer, format string, paired bool) *rowWriter {
+	if format == "json" {
+		return &rowWriter{json: out}
+	}
+	w := &rowWriter{csv: csv.NewWriter(out)}
+	if paired {
+		w.csv.Write([]string{"time", "coin", "score_a", "score_b", "diff",
+			"count_a", "count_b", "profile_a", "profile_b"})
+	} else {
+		w.csv.Write([]string{"time", "coin", "score", "count", "profile"})
+	}
+	return w
+}
+
+func (w *rowWriter) single(t time.Time, coin string, p point) error {
+	if w.csv != nil {
+		return w.csv.Write([]string{t.Format(time.RFC3339), coin,
+			formatFloat(p.Score), strconv.Itoa(p.Count), p.Profile})
+	}
+	return w.writeJSON(struct {
+		Time time.Time `json:"time"`
+		Coin string    `json:"coin"`
+		point
+	}{t, coin, p})
+}
+
+func (w *rowWriter) pair(t time.Time, coin string, a, b point) error {
+	if w.csv != nil {
+		return w.csv.Write([]string{t.Format(time.RFC3339), coin,
+			formatFloat(a.Score), formatFloat(b.Score), formatFloat(b.Score - a.Score),
+			strconv.Itoa(a.Count), strconv.Itoa(b.Count), a.Profile, b.Profile})
+	}
+	return w.writeJSON(struct {
+		Time time.Time `json:"time"`
+		Coin string    `json:"coin"`
+		A    point     `json:"a"`
+		B    point     `json:"b"`
+		Diff float64   `json:"diff"`
+	}{t, coin, a, b, b.Score - a.Score})
+}
+
+// writeJSON streams v as the next element of the output array.
+func (w *rowWriter) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if w.rows == 0 {
+		sep = "[\n"
+	}
+	w.rows++
+	_, err = io.WriteString(w.json, sep+string(data))
+	return err
+}
+
+func (w *rowWriter) close() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	end := "\n]\n"
+	if w.rows == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(w.json, end)
+	return err
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
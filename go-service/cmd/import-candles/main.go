@@ -0,0 +1,82 @@
+// Command import-candles loads OHLCV candles for one coin from a CSV file
+// into the price_candles table.
+//
+//	import-candles -coin BTC -file btc-1h.csv
+//
+// The CSV needs a header naming the time, open, high, low and close
+// columns; volume is optional. Candles already stored for the same open
+// time are overwritten.
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/market"
+)
+
+func main() {
+	coin := flag.String("coin", "", "coin code the candles belong to, e.g. BTC")
+	file := flag.String("file", "-", "CSV file to read, or - for stdin")
+	flag.Parse()
+	if *coin == "" {
+		log.Fatal("-coin is required")
+	}
+
+	// Load environment variables from .env file, if present
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+	settings := config.Defaults().Database
+	settings.URL = os.Getenv("DATABASE_URL")
+	if settings.URL == "" {
+		log.Fatal("DATABASE_URL is not set")
+	}
+
+	// 1) Parse the CSV before touching the database
+	var in io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", *file, err)
+		}
+		defer f.Close()
+		in = f
+	}
+	candles, err := market.ParseCandlesCSV(in)
+	if err != nil {
+		log.Fatalf("Failed to parse %s: %v", *file, err)
+	}
+	if len(candles) == 0 {
+		log.Fatalf("%s has no candles", *file)
+	}
+
+	// 2) Resolve the coin and store the candles
+	db.InitDB(settings)
+	db.EnsureSchema()
+	currencies, err := db.ListCurrencies()
+	if err != nil {
+		log.Fatalf("Failed to list coins: %v", err)
+	}
+	id := 0
+	for _, c := range currencies {
+		if c.Code == *coin {
+			id = c.ID
+		}
+	}
+	if id == 0 {
+		log.Fatalf("Unknown coin %q", *coin)
+	}
+	if err := db.UpsertCandles(id, candles); err != nil {
+		log.Fatalf("Failed to store candles: %v", err)
+	}
+	log.Printf("Imported %d %s candles (%s → %s)", len(candles), *coin,
+		candles[0].OpenTime.Format("2006-01-02 15:04"),
+		candles[len(candles)-1].OpenTime.Format("2006-01-02 15:04"))
+}
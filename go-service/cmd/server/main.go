@@ -1,6 +1,8 @@
 package main
 
 import (
+    "context"
+    "fmt"
     "io/ioutil"
     "log"
     "net/http"
@@ -9,9 +11,12 @@ import (
 
     "github.com/joho/godotenv"
 
+    "github.com/cosmic-hash/CryptoPulse/pkg/aggregate"
+    "github.com/cosmic-hash/CryptoPulse/pkg/coins"
     "github.com/cosmic-hash/CryptoPulse/pkg/config"
     "github.com/cosmic-hash/CryptoPulse/pkg/db"
     handlers "github.com/cosmic-hash/CryptoPulse/pkg/handler"
+	"github.com/cosmic-hash/CryptoPulse/pkg/events"
 	"github.com/cosmic-hash/CryptoPulse/pkg/firebase"
 	openai "github.com/cosmic-hash/CryptoPulse/pkg/openai"
 	 
@@ -22,31 +27,99 @@ func main() {
     if err := godotenv.Load(); err != nil {
         log.Println("No .env file found, falling back to env vars")
     }
-	// DEBUG: verify the key is there
-    //log.Printf("→ OPENAI_API_KEY=%q", os.Getenv("OPENAI_API_KEY"))
 
-    // 1.a) Initialize the OpenAI client now that the .env is loaded
-    openai.InitClient()
-    // 1.a) Debug: print out the DATABASE_URL you're using
-    // log.Printf("→ DATABASE_URL=%q", os.Getenv("DATABASE_URL"))
+    // 1.a) Resolve settings from defaults, the config file, env and flags
+    settings, opts, err := config.LoadSettings(os.Args[1:])
+    if opts.PrintConfig {
+        fmt.Print(settings.Redacted())
+        if err != nil {
+            fmt.Fprintln(os.Stderr, err)
+            os.Exit(1)
+        }
+        os.Exit(0)
+    }
+    if err != nil {
+        log.Fatalf("Invalid configuration: %v", err)
+    }
+    config.Current = settings
+
+    // 1.b) Initialize the OpenAI client now that the settings are loaded
+    openai.InitClient(settings.OpenAI)
 
     // 2) Init DB (will log fatal if it still can’t connect)
-    db.InitDB()
-	firebase.Init()
+    db.InitDB(settings.Database)
+    db.EnsureSchema()
+	firebase.Init(settings.Firebase.CredentialsFile)
 
-    // 3) Load question mapping
-    mappingPath := os.Getenv("QUESTION_MAPPING_FILE")
-    if mappingPath == "" {
-        mappingPath = "mapping.json"
-    }
-    data, err := ioutil.ReadFile(mappingPath)
+    // 3) Load the question registry from the questions table and
+    //    cross-check it against mapping.json when that is present
+    questions, err := db.FetchQuestions()
     if err != nil {
+        log.Fatalf("Error loading questions: %v", err)
+    }
+    if len(questions) == 0 {
+        log.Fatal("The questions table is empty; run the seeder first")
+    }
+    config.SetQuestions(questions)
+    log.Printf("Loaded %d questions", len(questions))
+
+    mappingPath := settings.Questions.MappingFile
+    if data, err := ioutil.ReadFile(mappingPath); err == nil {
+        mapping, err := config.ParseQuestionMapping(data)
+        if err != nil {
+            log.Fatalf("Error parsing mapping file: %v", err)
+        }
+        problems := config.CheckQuestionMapping(mapping)
+        for _, p := range problems {
+            log.Printf("[Questions] %s", p)
+        }
+        if len(problems) > 0 {
+            log.Fatalf("The questions table disagrees with %s in %d places; fix one or the other", mappingPath, len(problems))
+        }
+    } else if mappingPath != config.Defaults().Questions.MappingFile {
         log.Fatalf("Error reading mapping file %q: %v", mappingPath, err)
     }
-    if err := config.LoadQuestionMapping(data); err != nil {
-        log.Fatalf("Error parsing mapping file: %v", err)
+
+    // 3.a) Load the coin registry and keep it in sync with the currency table
+    if err := coins.Refresh(); err != nil {
+        log.Fatalf("Error loading coins: %v", err)
+    }
+    log.Printf("Loaded %d coins (%d enabled)", len(coins.All()), len(coins.Enabled()))
+    coins.StartRefresher(context.Background(), settings.Coins.RefreshInterval)
+
+    // 3.b) Load per-question weights and keep them fresh
+    if _, err := aggregate.ReloadWeights(); err != nil {
+        log.Fatalf("Invalid question weights: %v", err)
+    }
+    aggregate.WatchWeightsFile(context.Background(), settings.Weights.WatchInterval)
+
+    // 3.c) Load message filter, decay, source, author and event settings, then start the
+    //      background aggregation scheduler unless disabled
+    if err := aggregate.LoadFilterConfig(settings.Filters); err != nil {
+        log.Fatalf("Invalid filter settings: %v", err)
+    }
+    if err := aggregate.LoadDecayConfig(settings.Decay); err != nil {
+        log.Fatalf("Invalid decay settings: %v", err)
+    }
+    if err := aggregate.LoadSourceWeights(settings.Aggregation.SourceWeightsFile); err != nil {
+        log.Fatalf("Invalid source weights: %v", err)
+    }
+    if err := aggregate.LoadAuthorConfig(settings.Authors); err != nil {
+        log.Fatalf("Invalid author settings: %v", err)
+    }
+    if err := aggregate.LoadStrategies(settings.Aggregation.Strategies); err != nil {
+        log.Fatalf("Invalid aggregation strategies: %v", err)
+    }
+    events.Configure(settings.Events)
+    if settings.Aggregation.Scheduler {
+        aggregate.StartScheduler(context.Background(), handlers.AggregationCoins)
     }
 
+    // 3.d) Share live feed fetches between streaming clients, pushed as soon
+    //      as Postgres notifies about new aggregates and polled as a fallback
+    handlers.StartHub(context.Background(), settings.Server.StreamInterval)
+    db.ListenAggregates(context.Background(), handlers.NotifyAggregates)
+
 // // /alerts → both list (GET) and create (POST)
 // http.HandleFunc("/alerts", func(w http.ResponseWriter, r *http.Request) {
 // 	switch r.Method {
@@ -107,13 +180,23 @@ http.HandleFunc("/alerts", func(w http.ResponseWriter, r *http.Request) {
     http.HandleFunc("/", handlers.HelloHandler)
     http.HandleFunc("/sentiment", handlers.SentimentHandler)
     http.HandleFunc("/ws", handlers.WSHandler)
+    http.HandleFunc("/stream", handlers.StreamHandler)
 	http.HandleFunc("/aggregate", handlers.AggregateHandler)
+	http.HandleFunc("/aggregate/status", handlers.AggregateStatusHandler)
+	http.HandleFunc("/aggregate/recompute", handlers.RecomputeHandler)
 	http.HandleFunc("/explain", handlers.ExplainSentimentHandler)
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	log.Printf("🟢 Server listening on port %s", port)
-	log.Fatal(http.ListenAndServe(":" + port, nil))
+	http.HandleFunc("/topics", handlers.TopicsHandler)
+	http.HandleFunc("/correlation", handlers.CorrelationHandler)
+	http.HandleFunc("/events", handlers.EventsHandler)
+	http.HandleFunc("/indicators", handlers.IndicatorsHandler)
+	http.HandleFunc("/questions", handlers.QuestionsHandler)
+	http.HandleFunc("/coins", handlers.CoinsHandler)
+	http.HandleFunc("/coins/", handlers.CoinsHandler)
+	http.HandleFunc("/admin/authors", handlers.AuthorReputationHandler)
+	http.HandleFunc("/admin/weights", handlers.WeightsHandler)
+	http.HandleFunc("/admin/weights/reload", handlers.ReloadWeightsHandler)
+
+	port := settings.Server.Port
+	log.Printf("🟢 Server listening on port %d", port)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
 }
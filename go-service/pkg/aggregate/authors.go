@@ -0,0 +1,95 @@
+package aggregate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+// AuthorConfig makes scoring author-aware, so one prolific account cannot
+// outweigh many distinct users. The zero value turns it off.
+type AuthorConfig struct {
+	// RepeatFactor diminishes repeated posts by one author about a coin:
+	// the author's nth message (oldest first) within RepeatWindow weighs
+	// RepeatFactor^(n-1). 0 and 1 both leave repeats at full weight.
+	RepeatFactor float64 `json:"repeat_factor"`
+
+	// RepeatWindow is the trailing span repeats are counted over, so the
+	// count does not restart at every bucket edge. Zero counts repeats
+	// within the bucket being scored.
+	RepeatWindow Duration `json:"repeat_window"`
+
+	// Reputation scales every message by its author's weight in the
+	// author_reputation table. Authors missing from it weigh 1.
+	Reputation bool `json:"reputation"`
+}
+
+// DefaultAuthors is applied when a run does not override it.
+var DefaultAuthors AuthorConfig
+
+// Validate reports an invalid setting.
+func (a AuthorConfig) Validate() error {
+	if a.RepeatFactor < 0 || a.RepeatFactor > 1 {
+		return fmt.Errorf("repeat_factor must be between 0 and 1")
+	}
+	if a.RepeatWindow < 0 {
+		return fmt.Errorf("repeat_window must not be negative")
+	}
+	return nil
+}
+
+// LoadAuthorConfig validates s and installs it as DefaultAuthors.
+func LoadAuthorConfig(s config.AuthorSettings) error {
+	a := AuthorConfig{
+		RepeatFactor: s.RepeatFactor,
+		RepeatWindow: Duration(s.RepeatWindow),
+		Reputation:   s.Reputation,
+	}
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	DefaultAuthors = a
+	return nil
+}
+
+// reputations loads the reputation table when it is in use.
+func (a AuthorConfig) reputations() (map[string]float64, error) {
+	if !a.Reputation {
+		return nil, nil
+	}
+	rep, err := db.FetchAuthorWeights()
+	if err != nil {
+		return nil, fmt.Errorf("fetch author reputation: %w", err)
+	}
+	return rep, nil
+}
+
+// weights returns the author weight of each message in msgs, one coin's
+// kept messages ordered oldest first and reaching back RepeatWindow before
+// the first bucket that is scored. step is the width of the buckets they
+// are scored in. Messages without an author always weigh 1.
+func (a AuthorConfig) weights(msgs []db.MessageScore, rep map[string]float64, step time.Duration) []float64 {
+	out := make([]float64, len(msgs))
+	seen := map[string][]time.Time{} // earlier messages per author
+	for i, m := range msgs {
+		out[i] = 1
+		if m.Author == "" {
+			continue
+		}
+		if a.RepeatFactor > 0 && a.RepeatFactor < 1 {
+			from := trailingStart(m.CreatedAt, time.Duration(a.RepeatWindow), step)
+			prior := seen[m.Author]
+			n := len(prior) - sort.Search(len(prior), func(j int) bool { return !prior[j].Before(from) })
+			out[i] = math.Pow(a.RepeatFactor, float64(n))
+		}
+		seen[m.Author] = append(seen[m.Author], m.CreatedAt)
+		if w, ok := rep[m.Author]; ok {
+			out[i] *= w
+		}
+	}
+	return out
+}
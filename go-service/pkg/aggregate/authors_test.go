@@ -0,0 +1,69 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+func TestAuthorWeights(t *testing.T) {
+	msgs := []db.MessageScore{
+		scored(0, "ann", 0.1),
+		scored(3, "ann", 0.1),
+		scored(4, "", 0.1), // anonymous: never a repeat
+		scored(4, "", 0.1),
+		scored(6, "ann", 0.1), // a new bucket
+		scored(6, "bob", 0.1),
+		scored(62, "ann", 0.1), // 0 has left the trailing hour
+	}
+	for _, tc := range []struct {
+		name string
+		cfg  AuthorConfig
+		rep  map[string]float64
+		want []float64
+	}{
+		{"off", AuthorConfig{}, nil, []float64{1, 1, 1, 1, 1, 1, 1}},
+		{"factor 1", AuthorConfig{RepeatFactor: 1, RepeatWindow: Duration(time.Hour)}, nil, []float64{1, 1, 1, 1, 1, 1, 1}},
+		{"trailing hour", AuthorConfig{RepeatFactor: 0.5, RepeatWindow: Duration(time.Hour)}, nil,
+			[]float64{1, 0.5, 1, 1, 0.25, 1, 0.25}},
+		{"per bucket", AuthorConfig{RepeatFactor: 0.5}, nil,
+			[]float64{1, 0.5, 1, 1, 1, 1, 1}},
+		// reputation scales on top; missing authors weigh 1
+		{"reputation", AuthorConfig{RepeatFactor: 0.5}, map[string]float64{"ann": 2, "bob": 0},
+			[]float64{2, 1, 1, 1, 2, 0, 2}},
+	} {
+		got := tc.cfg.weights(msgs, tc.rep, 5*time.Minute)
+		for i := range got {
+			if !near(got[i], tc.want[i]) {
+				t.Errorf("%s: weights = %v, want %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestAuthorConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		cfg AuthorConfig
+		ok  bool
+	}{
+		{AuthorConfig{}, true},
+		{AuthorConfig{RepeatFactor: 0.5, RepeatWindow: Duration(time.Hour), Reputation: true}, true},
+		{AuthorConfig{RepeatFactor: 1}, true},
+		{AuthorConfig{RepeatFactor: 1.5}, false},
+		{AuthorConfig{RepeatFactor: -0.1}, false},
+		{AuthorConfig{RepeatWindow: Duration(-time.Hour)}, false},
+	} {
+		if err := tc.cfg.Validate(); (err == nil) != tc.ok {
+			t.Errorf("Validate(%+v) = %v, want ok %v", tc.cfg, err, tc.ok)
+		}
+	}
+}
+
+func TestAuthorReputationsOff(t *testing.T) {
+	// with reputation off the table is not read (db.Conn is nil here)
+	if rep, err := (AuthorConfig{}).reputations(); rep != nil || err != nil {
+		t.Fatalf("reputations = %v, %v; want none", rep, err)
+	}
+}
@@ -0,0 +1,62 @@
+package aggregate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// Backtest replays raw messages in [start, end) through the same levels
+// Run computes, up to opts.Resolution, but keeps every level in memory
+// instead of aggregated_sentiments. It works through the range chunk at
+// a time, so only one chunk of raw messages is held at once, and hands
+// each chunk's buckets at opts.Resolution to emit in time order.
+// opts.Store is ignored. The chunk is rounded up to whole windows of
+// opts.Resolution.
+func Backtest(coins []Coin, start, end time.Time, opts Options, chunk time.Duration, emit func([]Bucket) error) error {
+	target, err := model.LookupResolution(opts.Resolution)
+	if err != nil {
+		return err
+	}
+	if chunk < target.Step {
+		chunk = target.Step
+	}
+	chunk = ceilDuration(chunk, target.Step)
+	start = start.UTC().Truncate(target.Step)
+	end = end.UTC()
+
+	store := NewMemoryStore()
+	opts.Store = store
+	for from := start; from.Before(end); from = from.Add(chunk) {
+		to := from.Add(chunk)
+		if to.After(end) {
+			to = end
+		}
+		for _, res := range model.Resolutions {
+			levelOpts := opts
+			levelOpts.Resolution = res.Name
+			buckets, records, err := Compute(coins, from, to, levelOpts)
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", from.Format(time.RFC3339), res.Name, err)
+			}
+			store.Add(records)
+			if res.Name == target.Name {
+				if err := emit(buckets); err != nil {
+					return err
+				}
+				break
+			}
+		}
+		store.Prune(to)
+	}
+	return nil
+}
+
+// ceilDuration rounds d up to a multiple of step.
+func ceilDuration(d, step time.Duration) time.Duration {
+	if r := d % step; r != 0 {
+		return d + step - r
+	}
+	return d
+}
@@ -0,0 +1,74 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+func TestBacktest(t *testing.T) {
+	msgs := testMessages{
+		msg(at(0), "q1", 0.2, "ann", "reddit"),
+		msg(at(0.5), "q1", 0.4, "bob", "reddit"),
+		msg(at(3), "q1", 0.9, "cat", "reddit"),
+		msg(at(16), "q1", -0.1, "dan", "reddit"),
+	}
+	for _, chunk := range []time.Duration{time.Hour, 5 * time.Minute, 7 * time.Minute} {
+		var times []time.Time
+		var got []float64
+		opts := Options{Resolution: "5m", Weights: testWeights, Messages: msgs}
+		err := Backtest(btc, at(0), at(20), opts, chunk, func(buckets []Bucket) error {
+			for _, b := range buckets {
+				times = append(times, b.Time)
+				got = append(got, b.Coins["BTC"])
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 5m windows weigh each minute by its messages; the second and
+		// third carry the first across chunk edges
+		if want := []float64{0.5, 0.5, 0.5, -0.1}; !sameScores(got, want) {
+			t.Errorf("chunk %s: scores = %v, want %v", chunk, got, want)
+		}
+		for i := 1; i < len(times); i++ {
+			if !times[i].After(times[i-1]) {
+				t.Errorf("chunk %s: buckets out of order at %s", chunk, times[i])
+			}
+		}
+	}
+}
+
+func TestBacktestIgnoresStore(t *testing.T) {
+	// a stored score is not where a backtest carries from
+	mem := NewMemoryStore()
+	mem.Add([]db.AggregatedSentiment{row(1, -1, 0.8, 1)})
+	opts := Options{Resolution: "1m", Weights: testWeights, Messages: testMessages{}, Store: mem}
+	err := Backtest(btc, at(0), at(2), opts, time.Hour, func(buckets []Bucket) error {
+		for _, b := range buckets {
+			if b.Coins["BTC"] != 0 {
+				t.Errorf("%s carried a stored score %v", b.Time, b.Coins["BTC"])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCeilDuration(t *testing.T) {
+	for _, tc := range []struct {
+		d, step, want time.Duration
+	}{
+		{time.Hour, 5 * time.Minute, time.Hour},
+		{7 * time.Minute, 5 * time.Minute, 10 * time.Minute},
+		{time.Minute, 5 * time.Minute, 5 * time.Minute},
+	} {
+		if got := ceilDuration(tc.d, tc.step); got != tc.want {
+			t.Errorf("ceilDuration(%s, %s) = %s, want %s", tc.d, tc.step, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package aggregate
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+)
+
+// DecayConfig enables exponential time-decay weighting. A zero HalfLife
+// turns decay off: every message counts fully and carried-forward scores
+// repeat unchanged.
+type DecayConfig struct {
+	HalfLife Duration `json:"half_life"`
+}
+
+// DefaultDecay is applied when a run does not override decay.
+var DefaultDecay DecayConfig
+
+// Validate reports an invalid setting.
+func (d DecayConfig) Validate() error {
+	if d.HalfLife < 0 {
+		return fmt.Errorf("half_life must not be negative")
+	}
+	return nil
+}
+
+// LoadDecayConfig validates s and installs it as DefaultDecay.
+func LoadDecayConfig(s config.DecaySettings) error {
+	d := DecayConfig{HalfLife: Duration(s.HalfLife)}
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	DefaultDecay = d
+	return nil
+}
+
+// Factor returns the weight left after age has passed: 1 with decay off,
+// halving every HalfLife otherwise.
+func (d DecayConfig) Factor(age time.Duration) float64 {
+	if d.HalfLife <= 0 || age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(d.HalfLife))
+}
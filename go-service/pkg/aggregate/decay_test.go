@@ -0,0 +1,46 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecayFactor(t *testing.T) {
+	half := DecayConfig{HalfLife: Duration(10 * time.Minute)}
+	for _, tc := range []struct {
+		d    DecayConfig
+		age  time.Duration
+		want float64
+	}{
+		{DecayConfig{}, time.Hour, 1},
+		{half, 0, 1},
+		{half, -time.Minute, 1},
+		{half, 10 * time.Minute, 0.5},
+		{half, 30 * time.Minute, 0.125},
+		{half, 5 * time.Minute, 0.7071067811865476},
+	} {
+		if got := tc.d.Factor(tc.age); !near(got, tc.want) {
+			t.Errorf("Factor(%s) with half-life %s = %v, want %v", tc.age, time.Duration(tc.d.HalfLife), got, tc.want)
+		}
+	}
+
+	// decaying step by step compounds to the same as decaying at once, so
+	// a carried score depends only on its age
+	step := half.Factor(time.Minute)
+	compound := 1.0
+	for i := 0; i < 25; i++ {
+		compound *= step
+	}
+	if !near(compound, half.Factor(25*time.Minute)) {
+		t.Errorf("25 one-minute steps = %v, want %v", compound, half.Factor(25*time.Minute))
+	}
+}
+
+func TestDecayValidate(t *testing.T) {
+	if err := (DecayConfig{HalfLife: Duration(time.Hour)}).Validate(); err != nil {
+		t.Errorf("valid half-life rejected: %v", err)
+	}
+	if err := (DecayConfig{HalfLife: Duration(-time.Hour)}).Validate(); err == nil {
+		t.Error("negative half-life accepted")
+	}
+}
@@ -0,0 +1,537 @@
+package aggregate
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// Coin is one currency the engine computes buckets for.
+type Coin struct {
+	ID   int
+	Code string
+}
+
+// Options tune a single Compute run.
+type Options struct {
+	// Strategy names the model.Aggregator to use; empty means the default.
+	Strategy string
+	// Resolution names the bucket width; empty means the default.
+	Resolution string
+	// Filters overrides DefaultFilters for raw messages when set.
+	Filters *FilterConfig
+	// Decay overrides DefaultDecay when set.
+	Decay *DecayConfig
+	// Sources overrides DefaultSourceWeights when set.
+	Sources *SourceWeights
+	// Authors overrides DefaultAuthors when set.
+	Authors *AuthorConfig
+	// Profile forces every coin onto the named weight profile instead of
+	// its assigned one when set.
+	Profile string
+	// Weights replaces the installed weight profiles for this run when set.
+	Weights *model.WeightProfiles
+	// Store supplies the finer rows and last scores; nil reads the database.
+	Store Store
+	// Messages supplies the raw messages; nil reads the database.
+	Messages MessageSource
+}
+
+// Overridden reports whether o changes how scores are computed, rather
+// than only which series is computed. Such runs must not be stored: the
+// stored series is the one every client reads.
+func (o Options) Overridden() bool {
+	return o.Filters != nil || o.Decay != nil || o.Sources != nil ||
+		o.Authors != nil || o.Profile != "" || o.Weights != nil
+}
+
+// settings is the resolved configuration of one Compute run.
+type settings struct {
+	agg      model.Aggregator
+	res      model.Resolution
+	filters  FilterConfig
+	decay    DecayConfig
+	sources  SourceWeights
+	authors  AuthorConfig
+	profiles model.WeightProfiles // fixed for the run
+	profile  string               // forced profile, if any
+	codes    map[int]string       // coin ID → code
+	store    Store
+	messages MessageSource
+}
+
+func (o Options) resolve(coins []Coin) (settings, error) {
+	agg, err := model.LookupAggregator(o.Strategy)
+	if err != nil {
+		return settings{}, err
+	}
+	res, err := model.LookupResolution(o.Resolution)
+	if err != nil {
+		return settings{}, err
+	}
+	s := settings{
+		agg:      agg,
+		res:      res,
+		filters:  DefaultFilters,
+		decay:    DefaultDecay,
+		sources:  DefaultSourceWeights,
+		authors:  DefaultAuthors,
+		profiles: model.Profiles(),
+		codes:    make(map[int]string, len(coins)),
+		store:    o.Store,
+		messages: o.Messages,
+	}
+	if o.Weights != nil {
+		s.profiles = *o.Weights
+	}
+	if s.store == nil {
+		s.store = dbStore{}
+	}
+	if s.messages == nil {
+		s.messages = dbStore{}
+	}
+	if o.Filters != nil {
+		s.filters = *o.Filters
+	}
+	if o.Decay != nil {
+		s.decay = *o.Decay
+	}
+	if o.Sources != nil {
+		s.sources = *o.Sources
+	}
+	if o.Authors != nil {
+		s.authors = *o.Authors
+	}
+	if o.Profile != "" {
+		if _, ok := s.profiles.Profiles[o.Profile]; !ok {
+			return settings{}, fmt.Errorf("unknown weight profile %q", o.Profile)
+		}
+		s.profile = o.Profile
+	}
+	for _, c := range coins {
+		s.codes[c.ID] = c.Code
+	}
+	return s, nil
+}
+
+// profileFor returns the weight profile the coin with the given code is
+// scored with.
+func (s settings) profileFor(code string) (string, map[string]float64) {
+	if s.profile != "" {
+		return s.profile, s.profiles.Profiles[s.profile]
+	}
+	return s.profiles.For(code)
+}
+
+// Bucket is one computed window with a score and its stats per coin code.
+type Bucket struct {
+	Time       time.Time
+	Strategy   string
+	Resolution string
+	Coins      map[string]float64
+	Stats      map[string]model.BucketStats
+	// Topics holds the average score per question ID for each coin code.
+	Topics map[string]map[string]float64
+	// Sources holds the sub-score per message source for each coin code.
+	Sources map[string]map[string]float64
+	// Profiles holds the weight profile behind each coin code's score.
+	Profiles map[string]string
+}
+
+// coinScore is the fresh result for one coin in one window.
+type coinScore struct {
+	Sentiment float64
+	Stats     model.BucketStats
+	Topics    map[string]float64
+	Sources   map[string]float64
+	// Profile is the weight profile the score was computed with; empty
+	// when nothing was computed.
+	Profile string
+}
+
+// scoreFunc returns the fresh result of one coin in the window starting
+// at t, or false when there is nothing to compute from.
+type scoreFunc func(t time.Time, coinID int) (coinScore, bool)
+
+// Compute builds a bucket for every window starting in [start, end) and
+// every coin in coins. The base resolution is computed from raw messages;
+// coarser ones are rolled up from the stored rows one level finer. Coins
+// without data in a window carry forward their last known score, decaying
+// toward neutral, when time-decay is on, by the time since that score's
+// window. It returns the buckets together
+// with the rows the caller should persist.
+func Compute(coins []Coin, start, end time.Time, opts Options) ([]Bucket, []db.AggregatedSentiment, error) {
+	cfg, err := opts.resolve(coins)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := cfg.res
+	series := db.Series{Strategy: cfg.agg.Name(), Resolution: res.Name}
+	start = start.UTC().Truncate(res.Step)
+	end = end.UTC()
+
+	var windows []time.Time
+	for t := start; t.Before(end); t = t.Add(res.Step) {
+		windows = append(windows, t)
+	}
+
+	// 1) Collect per-window inputs: raw messages at the base, finer rows above it
+	var scoreFn scoreFunc
+	if finer, ok := res.Finer(); ok {
+		scoreFn, err = rollupScores(cfg, series, finer, start, end)
+	} else {
+		scoreFn, err = rawScores(cfg, start, end)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 2) Prefetch last-known sentiments
+	coinIDs := make([]int, 0, len(coins))
+	for _, c := range coins {
+		coinIDs = append(coinIDs, c.ID)
+	}
+	lastSent, err := cfg.store.LastScores(coinIDs, series, start)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch initial: %w", err)
+	}
+
+	// 3) Compute & collect
+	var (
+		buckets  []Bucket
+		toInsert []db.AggregatedSentiment
+	)
+	for _, t := range windows {
+		coinsOut := make(map[string]float64, len(coins))
+		statsOut := make(map[string]model.BucketStats, len(coins))
+		topicsOut := make(map[string]map[string]float64, len(coins))
+		sourcesOut := make(map[string]map[string]float64, len(coins))
+		profilesOut := make(map[string]string, len(coins))
+
+		for _, coin := range coins {
+			fresh, ok := scoreFn(t, coin.ID)
+			sent := fresh.Sentiment
+			if fresh.Profile == "" {
+				fresh.Profile, _ = cfg.profileFor(coin.Code)
+			}
+			if !ok {
+				// carry-forward or backfill, decaying toward neutral for as
+				// long as the last score is old
+				prev, found := lastSent[coin.ID]
+				if !found {
+					hist, err := cfg.store.LastScores([]int{coin.ID}, series, t)
+					if err != nil {
+						log.Printf("[Aggregate] backfill error for coin %d: %v", coin.ID, err)
+					}
+					prev, found = hist[coin.ID]
+				}
+				sent = 0
+				if found {
+					sent = prev.Score * cfg.decay.Factor(t.Sub(prev.WindowStart))
+				}
+			}
+			lastSent[coin.ID] = db.LastScore{WindowStart: t, Score: sent}
+
+			// Always schedule an insert, fresh or carried
+			toInsert = append(toInsert, db.AggregatedSentiment{
+				CurrencyID:     coin.ID,
+				WindowStart:    t,
+				SentimentScore: sent,
+				Strategy:       series.Strategy,
+				Resolution:     series.Resolution,
+				Stats:          fresh.Stats,
+				Topics:         fresh.Topics,
+				Sources:        fresh.Sources,
+				Profile:        fresh.Profile,
+			})
+			coinsOut[coin.Code] = sent
+			statsOut[coin.Code] = fresh.Stats
+			topicsOut[coin.Code] = fresh.Topics
+			sourcesOut[coin.Code] = fresh.Sources
+			profilesOut[coin.Code] = fresh.Profile
+		}
+
+		buckets = append(buckets, Bucket{
+			Time:       t,
+			Strategy:   series.Strategy,
+			Resolution: series.Resolution,
+			Coins:      coinsOut,
+			Stats:      statsOut,
+			Topics:     topicsOut,
+			Sources:    sourcesOut,
+			Profiles:   profilesOut,
+		})
+	}
+	return buckets, toInsert, nil
+}
+
+// rawScores groups raw messages in [start, end) by window and coin and
+// scores the ones the filters keep, weighting each message by its author,
+// its source and its age at the end of the window. The filters and the
+// author repeat count see each coin's messages as one stream, reaching
+// back as far as their longest span, so neither depends on window edges.
+// Each source is
+// also scored on its own, without the source weight.
+func rawScores(cfg settings, start, end time.Time) (scoreFunc, error) {
+	lookback := cfg.filters.lookback()
+	if repeat := time.Duration(cfg.authors.RepeatWindow); repeat > lookback {
+		lookback = repeat
+	}
+	raw, err := cfg.messages.RawBetween(start.Add(-lookback), end)
+	if err != nil {
+		return nil, fmt.Errorf("fetch raw: %w", err)
+	}
+	rep, err := cfg.authors.reputations()
+	if err != nil {
+		return nil, err
+	}
+	return scoreMessages(cfg, start, raw, rep), nil
+}
+
+// scoreMessages is rawScores over the fetched messages raw, oldest first
+// and reaching back before start as far as the lookback, and the author
+// reputations rep.
+func scoreMessages(cfg settings, start time.Time, raw []db.MessageScore, rep map[string]float64) scoreFunc {
+	perCoin := make(map[int][]db.MessageScore)
+	for _, m := range raw {
+		perCoin[m.CurrencyID] = append(perCoin[m.CurrencyID], m)
+	}
+	grouped := make(map[time.Time]map[int][]db.MessageScore)
+	authorW := make(map[time.Time]map[int][]float64)
+	dropped := make(map[time.Time]map[int]map[string]int)
+	for coinID, msgs := range perCoin {
+		var kept []db.MessageScore
+		for i, rule := range cfg.filters.Apply(msgs, cfg.res.Step) {
+			m := msgs[i]
+			if rule == "" {
+				kept = append(kept, m)
+				continue
+			}
+			if m.CreatedAt.Before(start) {
+				continue // lookback only
+			}
+			b := m.CreatedAt.UTC().Truncate(cfg.res.Step)
+			if dropped[b] == nil {
+				dropped[b] = make(map[int]map[string]int)
+			}
+			if dropped[b][coinID] == nil {
+				dropped[b][coinID] = map[string]int{}
+			}
+			dropped[b][coinID][rule]++
+		}
+		weights := cfg.authors.weights(kept, rep, cfg.res.Step)
+		for i, m := range kept {
+			if m.CreatedAt.Before(start) {
+				continue // lookback only
+			}
+			b := m.CreatedAt.UTC().Truncate(cfg.res.Step)
+			if grouped[b] == nil {
+				grouped[b] = make(map[int][]db.MessageScore)
+				authorW[b] = make(map[int][]float64)
+			}
+			grouped[b][coinID] = append(grouped[b][coinID], m)
+			authorW[b][coinID] = append(authorW[b][coinID], weights[i])
+		}
+	}
+
+	return func(t time.Time, coinID int) (coinScore, bool) {
+		msgs, authorW, dropped := grouped[t][coinID], authorW[t][coinID], dropped[t][coinID]
+		if dropped == nil {
+			dropped = map[string]int{}
+		}
+		if len(msgs) == 0 {
+			return coinScore{Stats: model.BucketStats{Dropped: dropped}}, false
+		}
+		windowEnd := t.Add(cfg.res.Step)
+		code := cfg.codes[coinID]
+		profile, weights := cfg.profileFor(code)
+		qScores := map[string][]model.Sample{}
+		bySource := map[string]map[string][]model.Sample{}
+		byID := map[string][]float64{}
+		sourceCounts := map[string]int{}
+		for i, m := range msgs {
+			byID[m.QuestionID] = append(byID[m.QuestionID], m.SentimentScore)
+			sourceCounts[m.Source]++
+			name := m.QuestionID
+			if _, ok := weights[name]; !ok {
+				continue
+			}
+			w := cfg.decay.Factor(windowEnd.Sub(m.CreatedAt)) * authorW[i]
+			qScores[name] = append(qScores[name], model.Sample{
+				Score:  m.SentimentScore,
+				Weight: w * cfg.sources.Weight(code, m.Source),
+			})
+			if bySource[m.Source] == nil {
+				bySource[m.Source] = map[string][]model.Sample{}
+			}
+			bySource[m.Source][name] = append(bySource[m.Source][name], model.Sample{
+				Score:  m.SentimentScore,
+				Weight: w,
+			})
+		}
+		// with no weight left (zero source or author weight, or decayed
+		// away) the score would be a neutral 0; carry the last one instead
+		mass := 0.0
+		for name, samples := range qScores {
+			for _, smp := range samples {
+				mass += weights[name] * smp.Weight
+			}
+		}
+		if mass == 0 {
+			return coinScore{Stats: model.BucketStats{Dropped: dropped}}, false
+		}
+		sources := make(map[string]float64, len(bySource))
+		for src, samples := range bySource {
+			sources[src] = cfg.agg.Aggregate(weights, samples)
+		}
+
+		stats := model.NewBucketStats(byID)
+		stats.Dropped = dropped
+		stats.SourceCounts = sourceCounts
+		return coinScore{
+			Sentiment: cfg.agg.Aggregate(weights, qScores),
+			Stats:     stats,
+			Topics:    model.QuestionAverages(byID),
+			Sources:   sources,
+			Profile:   profile,
+		}, true
+	}
+}
+
+// rollupScores combines the finer rows in cfg.store that fall inside each
+// window, weighting each score by its message count and, with decay on,
+// by the finer row's age at the end of the window.
+// Carried-forward rows (no messages) do not contribute, and a window made
+// of nothing else is carried forward itself. This replaced the first
+// rollup rule, a plain average of every finer row, carried or not: a
+// carried score repeats old messages, so averaging it in let a single
+// busy minute be outvoted by quiet ones. Rows stored before message
+// counts were (message_count 0) look carried too; recompute their range
+// to roll them up again. The rollup keeps the finer rows' weight profile,
+// or mixedProfile when they differ.
+func rollupScores(cfg settings, series db.Series, finer model.Resolution, start, end time.Time) (scoreFunc, error) {
+	rows, err := cfg.store.AggregatedBetween(start, end,
+		db.Series{Strategy: series.Strategy, Resolution: finer.Name})
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s rows: %w", finer.Name, err)
+	}
+	grouped := make(map[time.Time]map[int][]db.AggregatedSentiment)
+	for _, a := range rows {
+		if !a.WindowStart.Before(end) {
+			continue
+		}
+		b := a.WindowStart.UTC().Truncate(cfg.res.Step)
+		if grouped[b] == nil {
+			grouped[b] = make(map[int][]db.AggregatedSentiment)
+		}
+		grouped[b][a.CurrencyID] = append(grouped[b][a.CurrencyID], a)
+	}
+
+	return func(t time.Time, coinID int) (coinScore, bool) {
+		parts := grouped[t][coinID]
+		windowEnd := t.Add(cfg.res.Step)
+		sum, mass := 0.0, 0.0
+		stats := make([]model.BucketStats, 0, len(parts))
+		topics := make([]map[string]float64, 0, len(parts))
+		topicCounts := make([]map[string]int, 0, len(parts))
+		sources := make([]map[string]float64, 0, len(parts))
+		sourceCounts := make([]map[string]int, 0, len(parts))
+		profile := ""
+		for _, p := range parts {
+			age := windowEnd.Sub(p.WindowStart.Add(finer.Step))
+			w := float64(p.Stats.Count) * cfg.decay.Factor(age)
+			sum += p.SentimentScore * w
+			mass += w
+			stats = append(stats, p.Stats)
+			topics = append(topics, p.Topics)
+			topicCounts = append(topicCounts, p.Stats.QuestionCounts)
+			sources = append(sources, p.Sources)
+			sourceCounts = append(sourceCounts, p.Stats.SourceCounts)
+			if p.Stats.Count > 0 {
+				profile = mergeProfile(profile, p.Profile)
+			}
+		}
+		merged := model.MergeBucketStats(stats)
+		if mass == 0 {
+			return coinScore{Stats: model.BucketStats{Dropped: merged.Dropped}}, false
+		}
+		return coinScore{
+			Sentiment: sum / mass,
+			Stats:     merged,
+			Topics:    model.MergeKeyedAverages(topics, topicCounts),
+			Sources:   model.MergeKeyedAverages(sources, sourceCounts),
+			Profile:   profile,
+		}, true
+	}, nil
+}
+
+// mixedProfile marks a rollup whose finer rows used different profiles.
+const mixedProfile = "mixed"
+
+// mergeProfile folds the profile of one more finer row into acc.
+func mergeProfile(acc, p string) string {
+	if acc == "" || acc == p {
+		return p
+	}
+	return mixedProfile
+}
+
+// Run computes every resolution from the base up to opts.Resolution over
+// [start, end), so each level can be rolled up from the one below it, and
+// returns the buckets at opts.Resolution. Only windows that have closed
+// (as the scheduler sees them) are stored: stored rows are never
+// rewritten, so storing an open window would freeze its partial score.
+// Open windows are computed and returned but only kept in memory, for the
+// coarser levels of this run to roll up. The anomaly detectors run over
+// the rows it inserts. A run with overridden settings stores nothing and
+// keeps every window in memory instead.
+func Run(coins []Coin, start, end time.Time, opts Options) ([]Bucket, error) {
+	target, err := model.LookupResolution(opts.Resolution)
+	if err != nil {
+		return nil, err
+	}
+	start = start.UTC().Truncate(target.Step)
+	now := time.Now().UTC().Add(-settleDelay)
+	base := opts.Store
+	if base == nil {
+		base = dbStore{}
+	}
+	open := NewMemoryStore()
+	store := !opts.Overridden()
+
+	for _, res := range model.Resolutions {
+		levelOpts := opts
+		levelOpts.Resolution = res.Name
+		levelOpts.Store = overlayStore{base: base, mem: open}
+		buckets, records, err := Compute(coins, start, end, levelOpts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", res.Name, err)
+		}
+
+		closed := now.Truncate(res.Step)
+		final := make([]db.AggregatedSentiment, 0, len(records))
+		var pending []db.AggregatedSentiment
+		for _, r := range records {
+			if store && r.WindowStart.Before(closed) {
+				final = append(final, r)
+			} else {
+				pending = append(pending, r)
+			}
+		}
+		inserted, err := db.InsertAggregatedSentimentBatch(final)
+		if err != nil {
+			return nil, fmt.Errorf("%s insert: %w", res.Name, err)
+		}
+		detect("[Aggregate]", res, inserted)
+		open.Add(pending)
+		log.Printf("[Aggregate] stored %d %s records, kept %d unstored",
+			len(final), res.Name, len(pending))
+		if res.Name == target.Name {
+			return buckets, nil
+		}
+	}
+	return nil, fmt.Errorf("resolution %q not reached", target.Name)
+}
@@ -0,0 +1,242 @@
+package aggregate
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+var testStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+// at is testStart plus m minutes.
+func at(m float64) time.Time { return testStart.Add(time.Duration(m * float64(time.Minute))) }
+
+// testSettings scores BTC (ID 1) at 1m with the weighted mean and a
+// default profile of two questions, with every other setting off.
+func testSettings() settings {
+	res, _ := model.LookupResolution("1m")
+	return settings{
+		agg: model.WeightedMean{},
+		res: res,
+		profiles: model.WeightProfiles{Profiles: map[string]map[string]float64{
+			model.DefaultProfile: {"q1": 0.75, "q2": 0.25},
+		}},
+		codes: map[int]string{1: "BTC"},
+		store: NewMemoryStore(),
+	}
+}
+
+// msg is a message about BTC.
+func msg(t time.Time, question string, score float64, author, source string) db.MessageScore {
+	return db.MessageScore{QuestionID: question, CurrencyID: 1, SentimentScore: score, CreatedAt: t, Author: author, Source: source}
+}
+
+func TestScoreMessages(t *testing.T) {
+	msgs := []db.MessageScore{
+		msg(at(0.1), "q1", 0.4, "ann", "reddit"),
+		msg(at(0.2), "q1", 0.8, "bob", "news"),
+		msg(at(0.3), "q2", -0.4, "cat", "reddit"),
+	}
+	for _, tc := range []struct {
+		name  string
+		edit  func(cfg *settings)
+		rep   map[string]float64
+		want  float64
+		fresh bool
+	}{
+		{"plain", func(*settings) {}, nil, 0.75*0.6 + 0.25*-0.4, true},
+		{"source weight", func(cfg *settings) {
+			cfg.sources = SourceWeights{Default: map[string]float64{"news": 3}}
+		}, nil, 0.75*0.7 + 0.25*-0.4, true},
+		{"reputation", nil, map[string]float64{"bob": 0}, 0.75*0.4 + 0.25*-0.4, true},
+		// no weight left anywhere: carried, not a neutral 0
+		{"zero source weights", func(cfg *settings) {
+			cfg.sources = SourceWeights{Default: map[string]float64{"news": 0, "reddit": 0}}
+		}, nil, 0, false},
+		{"zero reputations", nil, map[string]float64{"ann": 0, "bob": 0, "cat": 0}, 0, false},
+		{"zero question weights", func(cfg *settings) {
+			cfg.profiles.Profiles[model.DefaultProfile] = map[string]float64{"q1": 0, "q2": 0, "q3": 1}
+		}, nil, 0, false},
+	} {
+		cfg := testSettings()
+		if tc.edit != nil {
+			tc.edit(&cfg)
+		}
+		score, fresh := scoreMessages(cfg, testStart, msgs, tc.rep)(testStart, 1)
+		if fresh != tc.fresh || !near(score.Sentiment, tc.want) {
+			t.Errorf("%s: score = %v, %v; want %v, %v", tc.name, score.Sentiment, fresh, tc.want, tc.fresh)
+			continue
+		}
+		if !fresh && score.Stats.Count != 0 {
+			t.Errorf("%s: carried score has count %d, want 0 so rollups skip it", tc.name, score.Stats.Count)
+		}
+		if fresh && (score.Stats.Count != 3 || score.Profile != model.DefaultProfile) {
+			t.Errorf("%s: stats = %+v, profile %q", tc.name, score.Stats, score.Profile)
+		}
+	}
+}
+
+func TestScoreMessagesSkipsLookback(t *testing.T) {
+	msgs := []db.MessageScore{
+		msg(at(-0.5), "q1", 0.9, "ann", "reddit"), // lookback only
+		msg(at(0.5), "q1", 0.1, "bob", "reddit"),
+	}
+	score := scoreMessages(testSettings(), testStart, msgs, nil)
+	if got, fresh := score(testStart.Add(-time.Minute), 1); fresh {
+		t.Errorf("lookback window scored: %+v", got)
+	}
+	if got, fresh := score(testStart, 1); !fresh || !near(got.Sentiment, 0.1) || got.Stats.Count != 1 {
+		t.Errorf("window = %+v, %v; want 0.1 from one message", got, fresh)
+	}
+	if _, fresh := score(testStart, 2); fresh {
+		t.Error("a coin without messages was scored")
+	}
+}
+
+// testMessages serves raw messages from memory.
+type testMessages []db.MessageScore
+
+func (m testMessages) RawBetween(start, end time.Time) ([]db.MessageScore, error) {
+	var out []db.MessageScore
+	for _, x := range m {
+		if !x.CreatedAt.Before(start) && x.CreatedAt.Before(end) {
+			out = append(out, x)
+		}
+	}
+	return out, nil
+}
+
+// testWeights scores question q1 alone.
+var testWeights = &model.WeightProfiles{Profiles: map[string]map[string]float64{model.DefaultProfile: {"q1": 1}}}
+
+var btc = []Coin{{ID: 1, Code: "BTC"}}
+
+// rowScores lists the score of each record, oldest first.
+func rowScores(records []db.AggregatedSentiment) []float64 {
+	out := make([]float64, len(records))
+	for i, r := range records {
+		out[i] = r.SentimentScore
+	}
+	return out
+}
+
+func TestComputeBaseCarriesForward(t *testing.T) {
+	msgs := testMessages{msg(at(0.5), "q1", 0.4, "ann", "reddit")}
+	for _, tc := range []struct {
+		name  string
+		decay *DecayConfig
+		want  []float64
+	}{
+		{"no decay", nil, []float64{0.4, 0.4, 0.4}},
+		// toward neutral by the age of the last fresh score
+		{"decay", &DecayConfig{HalfLife: Duration(time.Minute)}, []float64{0.4, 0.2, 0.1}},
+	} {
+		opts := Options{Resolution: "1m", Decay: tc.decay, Weights: testWeights, Messages: msgs, Store: NewMemoryStore()}
+		_, records, err := Compute(btc, at(0), at(3), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rowScores(records); !sameScores(got, tc.want) {
+			t.Errorf("%s: scores = %v, want %v", tc.name, got, tc.want)
+		}
+		if records[0].Stats.Count != 1 || records[1].Stats.Count != 0 || records[2].Stats.Count != 0 {
+			t.Errorf("%s: carried rows must have no messages: %+v", tc.name, records)
+		}
+	}
+}
+
+func TestComputeBackfillsFromStore(t *testing.T) {
+	mem := NewMemoryStore()
+	last := row(1, -2, 0.8, 1) // two minutes before the range
+	mem.Add([]db.AggregatedSentiment{last})
+	opts := Options{
+		Resolution: "1m", Decay: &DecayConfig{HalfLife: Duration(time.Minute)},
+		Weights: testWeights, Messages: testMessages{}, Store: mem,
+	}
+	_, records, err := Compute(btc, at(0), at(2), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rowScores(records), []float64{0.2, 0.1}; !sameScores(got, want) {
+		t.Errorf("scores = %v, want %v", got, want)
+	}
+}
+
+func TestComputeRollup(t *testing.T) {
+	mem := NewMemoryStore()
+	fresh0, fresh1 := row(1, 0, 0.6, 3), row(1, 1, -0.2, 1)
+	fresh0.Profile, fresh1.Profile = "default", "alt"
+	carried := row(1, 2, 0.9, 0)
+	carried.Profile = "other"
+	mem.Add([]db.AggregatedSentiment{fresh0, fresh1, carried, row(1, 5, 0.3, 0), row(1, 6, 0.3, 0)})
+
+	half := DecayConfig{HalfLife: Duration(5 * time.Minute)}
+	w0, w1 := half.Factor(4*time.Minute)*3, half.Factor(3*time.Minute)*1 // by age at the window end
+	decayed := (0.6*w0 - 0.2*w1) / (w0 + w1)
+	for _, tc := range []struct {
+		name  string
+		decay *DecayConfig
+		want  []float64
+	}{
+		// weighted by message count; the carried row does not count, and a
+		// window of carried rows carries the previous window
+		{"by count", nil, []float64{0.4, 0.4, 0.4}},
+		{"decay", &half, []float64{decayed, decayed * 0.5, decayed * 0.25}},
+	} {
+		opts := Options{Resolution: "5m", Decay: tc.decay, Store: mem}
+		_, records, err := Compute(btc, at(0), at(15), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rowScores(records); !sameScores(got, tc.want) {
+			t.Errorf("%s: scores = %v, want %v", tc.name, got, tc.want)
+		}
+		if r := records[0]; r.Stats.Count != 4 || r.Profile != mixedProfile || r.Resolution != "5m" {
+			t.Errorf("%s: first window count %d profile %q resolution %s, want 4, %s, 5m",
+				tc.name, r.Stats.Count, r.Profile, r.Resolution, mixedProfile)
+		}
+		if r := records[1]; r.Stats.Count != 0 {
+			t.Errorf("%s: carried window has count %d", tc.name, r.Stats.Count)
+		}
+	}
+}
+
+func TestMergeProfile(t *testing.T) {
+	for _, tc := range []struct {
+		acc, p, want string
+	}{
+		{"", "default", "default"},
+		{"default", "default", "default"},
+		{"default", "alt", mixedProfile},
+		{mixedProfile, "default", mixedProfile},
+	} {
+		if got := mergeProfile(tc.acc, tc.p); got != tc.want {
+			t.Errorf("mergeProfile(%q, %q) = %q, want %q", tc.acc, tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestOptionsOverridden(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts Options
+		want bool
+	}{
+		{"series only", Options{Strategy: "trimmed_mean", Resolution: "1h", Store: NewMemoryStore()}, false},
+		{"filters", Options{Filters: &FilterConfig{}}, true},
+		{"decay", Options{Decay: &DecayConfig{}}, true},
+		{"sources", Options{Sources: &SourceWeights{}}, true},
+		{"authors", Options{Authors: &AuthorConfig{}}, true},
+		{"profile", Options{Profile: "alt"}, true},
+		{"weights", Options{Weights: testWeights}, true},
+	} {
+		if got := tc.opts.Overridden(); got != tc.want {
+			t.Errorf("%s: Overridden = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
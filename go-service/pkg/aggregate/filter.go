@@ -0,0 +1,231 @@
+package aggregate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+// Filter rule names, as reported in drop counts.
+const (
+	RuleAuthorCap = "author_cap"
+	RuleBurst     = "burst"
+	RuleOutlier   = "outlier"
+)
+
+// FilterConfig configures the filtering stage that runs on each coin's raw
+// messages before they are scored. A zero value disables the
+// corresponding rule.
+type FilterConfig struct {
+	// MaxPerAuthor keeps at most this many messages per author within any
+	// Window.
+	MaxPerAuthor int `json:"max_per_author"`
+
+	// BurstLimit and BurstWindow damp spam bursts: once BurstLimit messages
+	// for a coin land within BurstWindow, further ones in that span are dropped.
+	BurstLimit  int      `json:"burst_limit"`
+	BurstWindow Duration `json:"burst_window"`
+
+	// OutlierMethod is "mad" (median absolute deviation) or "zscore".
+	// Scores further than OutlierThreshold from the centre of the Window
+	// ending with their bucket are dropped.
+	OutlierMethod    string  `json:"outlier_method"`
+	OutlierThreshold float64 `json:"outlier_threshold"`
+
+	// Window is the trailing span the author cap and outlier rejection
+	// look at, so their result does not depend on where bucket edges fall.
+	// Zero limits them to the bucket being scored.
+	Window Duration `json:"window"`
+}
+
+// Duration is a time.Duration that reads and writes strings such as "30s".
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// DefaultFilters is applied when a run does not override the filters.
+var DefaultFilters FilterConfig
+
+// Validate reports the first invalid setting.
+func (f FilterConfig) Validate() error {
+	switch f.OutlierMethod {
+	case "", "mad", "zscore":
+	default:
+		return fmt.Errorf("unknown outlier_method %q", f.OutlierMethod)
+	}
+	if f.OutlierMethod != "" && f.OutlierThreshold <= 0 {
+		return fmt.Errorf("outlier_threshold must be positive")
+	}
+	if f.MaxPerAuthor < 0 || f.BurstLimit < 0 || f.BurstWindow < 0 || f.Window < 0 {
+		return fmt.Errorf("filter limits must not be negative")
+	}
+	if (f.BurstLimit > 0) != (f.BurstWindow > 0) {
+		return fmt.Errorf("burst_limit and burst_window must be set together")
+	}
+	return nil
+}
+
+// LoadFilterConfig validates s and installs it as DefaultFilters.
+func LoadFilterConfig(s config.FilterSettings) error {
+	f := FilterConfig{
+		MaxPerAuthor:     s.MaxPerAuthor,
+		BurstLimit:       s.BurstLimit,
+		BurstWindow:      Duration(s.BurstWindow),
+		OutlierMethod:    s.OutlierMethod,
+		OutlierThreshold: s.OutlierThreshold,
+		Window:           Duration(s.Window),
+	}
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	DefaultFilters = f
+	return nil
+}
+
+// lookback is how far before a bucket the filters look for the messages
+// that decide what is dropped in it.
+func (f FilterConfig) lookback() time.Duration {
+	if f.BurstWindow > f.Window {
+		return time.Duration(f.BurstWindow)
+	}
+	return time.Duration(f.Window)
+}
+
+// Apply runs every enabled rule over one coin's messages, which must be
+// ordered oldest first and reach back lookback before the first bucket
+// that is scored. step is the width of the buckets they are scored in.
+// It returns, for each message, the rule that dropped it, or "" if it
+// was kept.
+func (f FilterConfig) Apply(msgs []db.MessageScore, step time.Duration) []string {
+	reasons := make([]string, len(msgs))
+	var kept []int // indices of the messages kept so far, oldest first
+
+	// 1) per-author cap over the trailing window
+	for i, m := range msgs {
+		if f.MaxPerAuthor > 0 && m.Author != "" {
+			from := trailingStart(m.CreatedAt, time.Duration(f.Window), step)
+			n := 0
+			for j := len(kept) - 1; j >= 0 && !msgs[kept[j]].CreatedAt.Before(from); j-- {
+				if msgs[kept[j]].Author == m.Author {
+					n++
+				}
+			}
+			if n >= f.MaxPerAuthor {
+				reasons[i] = RuleAuthorCap
+				continue
+			}
+		}
+		kept = append(kept, i)
+	}
+
+	// 2) burst damping over a sliding window
+	if f.BurstLimit > 0 {
+		window := time.Duration(f.BurstWindow)
+		next := kept[:0:0]
+		for _, i := range kept {
+			recent := 0
+			for j := len(next) - 1; j >= 0 && msgs[i].CreatedAt.Sub(msgs[next[j]].CreatedAt) < window; j-- {
+				recent++
+			}
+			if recent >= f.BurstLimit {
+				reasons[i] = RuleBurst
+				continue
+			}
+			next = append(next, i)
+		}
+		kept = next
+	}
+
+	// 3) outlier rejection, each bucket against the trailing window ending
+	//    with it
+	if f.OutlierMethod != "" {
+		span := time.Duration(f.Window)
+		if span < step {
+			span = step
+		}
+		times := make([]time.Time, len(kept))
+		for k, i := range kept {
+			times[k] = msgs[i].CreatedAt
+		}
+		for lo := 0; lo < len(kept); {
+			bucket := times[lo].Truncate(step)
+			end := bucket.Add(step)
+			hi := sort.Search(len(times), func(k int) bool { return !times[k].Before(end) })
+			from := sort.Search(len(times), func(k int) bool { return !times[k].Before(end.Add(-span)) })
+			if hi-from >= 3 {
+				scores := make([]float64, 0, hi-from)
+				for _, i := range kept[from:hi] {
+					scores = append(scores, msgs[i].SentimentScore)
+				}
+				centre, spread := outlierScale(f.OutlierMethod, scores)
+				if spread > 0 {
+					for _, i := range kept[lo:hi] {
+						if math.Abs(msgs[i].SentimentScore-centre)/spread > f.OutlierThreshold {
+							reasons[i] = RuleOutlier
+						}
+					}
+				}
+			}
+			lo = hi
+		}
+	}
+	return reasons
+}
+
+// trailingStart returns the earliest time that still counts as recent for
+// a message at t: window before t, or with no window the start of t's
+// bucket.
+func trailingStart(t time.Time, window, step time.Duration) time.Time {
+	if window > 0 {
+		return t.Add(-window)
+	}
+	return t.Truncate(step)
+}
+
+// outlierScale returns the centre and unit spread used to score outliers.
+func outlierScale(method string, scores []float64) (float64, float64) {
+	if method == "mad" {
+		med := median(scores)
+		dev := make([]float64, len(scores))
+		for i, s := range scores {
+			dev[i] = math.Abs(s - med)
+		}
+		// 1.4826 scales the MAD to a standard deviation for normal data
+		return med, 1.4826 * median(dev)
+	}
+	mean := 0.0
+	for _, s := range scores {
+		mean += s
+	}
+	mean /= float64(len(scores))
+	variance := 0.0
+	for _, s := range scores {
+		variance += (s - mean) * (s - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(scores)))
+}
+
+func median(xs []float64) float64 {
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,188 @@
+package aggregate
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+// scored is a message about BTC by author at m minutes with score s.
+func scored(m float64, author string, s float64) db.MessageScore {
+	return msg(at(m), "q1", s, author, "reddit")
+}
+
+func TestFilterAuthorCap(t *testing.T) {
+	msgs := []db.MessageScore{
+		scored(0, "ann", 0.1),
+		scored(3, "ann", 0.1),
+		scored(4, "", 0.1), // anonymous messages are never capped
+		scored(4.5, "", 0.1),
+		scored(6, "ann", 0.1), // a new bucket, but the same trailing hour
+		scored(6, "bob", 0.1),
+		scored(59, "ann", 0.1), // 0 and 3 are still within the hour
+		scored(64, "ann", 0.1), // they are not now, and dropped ones do not count
+	}
+	for _, tc := range []struct {
+		name   string
+		window time.Duration
+		want   []string
+	}{
+		{"trailing hour", time.Hour, []string{"", "", "", "", RuleAuthorCap, "", RuleAuthorCap, ""}},
+		// per bucket, the cap restarts at every bucket edge
+		{"per bucket", 0, []string{"", "", "", "", "", "", "", ""}},
+	} {
+		f := FilterConfig{MaxPerAuthor: 2, Window: Duration(tc.window)}
+		if got := f.Apply(msgs, 5*time.Minute); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: reasons = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	f := FilterConfig{MaxPerAuthor: 1}
+	got := f.Apply([]db.MessageScore{scored(0, "ann", 0), scored(1, "ann", 0), scored(5, "ann", 0)}, 5*time.Minute)
+	if want := []string{"", RuleAuthorCap, ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("per bucket cap of 1: reasons = %q, want %q", got, want)
+	}
+}
+
+func TestFilterBurst(t *testing.T) {
+	sec := func(s float64, author string) db.MessageScore { return scored(s/60, author, 0.1) }
+	msgs := []db.MessageScore{
+		sec(0, "ann"),
+		sec(10, "bob"),
+		sec(20, "cat"), // the third within a minute
+		sec(59, "dan"), // still two kept within the minute before it
+		sec(70, "eve"), // 10s is now more than a minute back
+		sec(75, "fay"),
+		sec(76, "ann"),
+	}
+	f := FilterConfig{BurstLimit: 2, BurstWindow: Duration(time.Minute)}
+	want := []string{"", "", RuleBurst, RuleBurst, "", "", RuleBurst}
+	if got := f.Apply(msgs, 5*time.Minute); !reflect.DeepEqual(got, want) {
+		t.Errorf("reasons = %q, want %q", got, want)
+	}
+
+	// messages the author cap drops do not count toward a burst
+	f.MaxPerAuthor = 1
+	f.Window = Duration(time.Hour)
+	msgs = []db.MessageScore{sec(0, "ann"), sec(5, "ann"), sec(10, "bob"), sec(15, "cat")}
+	want = []string{"", RuleAuthorCap, "", RuleBurst}
+	if got := f.Apply(msgs, 5*time.Minute); !reflect.DeepEqual(got, want) {
+		t.Errorf("with the author cap: reasons = %q, want %q", got, want)
+	}
+}
+
+func TestFilterOutliers(t *testing.T) {
+	// one bucket of ordinary scores and a spike
+	bucket := []db.MessageScore{
+		scored(0, "a", 0.10), scored(1, "b", 0.20), scored(2, "c", 0.15),
+		scored(3, "d", 0.12), scored(4, "e", 0.90),
+	}
+	zscores := []db.MessageScore{
+		scored(0, "a", 0), scored(0.5, "b", 0), scored(1, "c", 0), scored(1.5, "d", 0), scored(2, "e", 0),
+		scored(2.5, "f", 0), scored(3, "g", 0), scored(3.5, "h", 0), scored(4, "i", 0), scored(4.5, "j", 1),
+	}
+	for _, tc := range []struct {
+		name string
+		f    FilterConfig
+		msgs []db.MessageScore
+		want []string
+	}{
+		// median 0.15, MAD 0.05: the spike is 0.75 / (1.4826 * 0.05) ≈ 10 out
+		{"mad", FilterConfig{OutlierMethod: "mad", OutlierThreshold: 3}, bucket,
+			[]string{"", "", "", "", RuleOutlier}},
+		{"mad lenient", FilterConfig{OutlierMethod: "mad", OutlierThreshold: 20}, bucket,
+			[]string{"", "", "", "", ""}},
+		// mean 0.1, std 0.3: the 1 is 3 standard deviations out
+		{"zscore", FilterConfig{OutlierMethod: "zscore", OutlierThreshold: 2.5}, zscores,
+			[]string{"", "", "", "", "", "", "", "", "", RuleOutlier}},
+		{"zscore lenient", FilterConfig{OutlierMethod: "zscore", OutlierThreshold: 3.5}, zscores,
+			[]string{"", "", "", "", "", "", "", "", "", ""}},
+		{"too few", FilterConfig{OutlierMethod: "mad", OutlierThreshold: 1},
+			[]db.MessageScore{scored(0, "a", 0.1), scored(1, "b", 0.9)}, []string{"", ""}},
+	} {
+		if got := tc.f.Apply(tc.msgs, 5*time.Minute); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: reasons = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFilterOutliersTrailingWindow(t *testing.T) {
+	// a lone spike half an hour after a quiet bucket: alone in its bucket
+	// there is nothing to judge it against, but the trailing hour has
+	// enough to show it is out of line
+	msgs := []db.MessageScore{
+		scored(0, "a", 0.10), scored(1, "b", 0.20), scored(2, "c", 0.15), scored(3, "d", 0.12),
+		scored(31, "e", 0.90),
+	}
+	f := FilterConfig{OutlierMethod: "mad", OutlierThreshold: 3}
+	if got := f.Apply(msgs, 5*time.Minute); got[4] != "" {
+		t.Errorf("per bucket: spike dropped as %q", got[4])
+	}
+	f.Window = Duration(time.Hour)
+	if got := f.Apply(msgs, 5*time.Minute); got[4] != RuleOutlier {
+		t.Errorf("trailing hour: spike reason = %q, want %s", got[4], RuleOutlier)
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	for _, tc := range []struct {
+		f  FilterConfig
+		ok bool
+	}{
+		{FilterConfig{}, true},
+		{FilterConfig{OutlierMethod: "mad", OutlierThreshold: 3, MaxPerAuthor: 5, Window: Duration(time.Hour)}, true},
+		{FilterConfig{BurstLimit: 10, BurstWindow: Duration(time.Minute)}, true},
+		{FilterConfig{OutlierMethod: "iqr", OutlierThreshold: 3}, false},
+		{FilterConfig{OutlierMethod: "zscore"}, false},
+		{FilterConfig{MaxPerAuthor: -1}, false},
+		{FilterConfig{Window: Duration(-time.Minute)}, false},
+		{FilterConfig{BurstLimit: 10}, false},
+		{FilterConfig{BurstWindow: Duration(time.Minute)}, false},
+	} {
+		if err := tc.f.Validate(); (err == nil) != tc.ok {
+			t.Errorf("Validate(%+v) = %v, want ok %v", tc.f, err, tc.ok)
+		}
+	}
+}
+
+func TestFilterLookback(t *testing.T) {
+	for _, tc := range []struct {
+		f    FilterConfig
+		want time.Duration
+	}{
+		{FilterConfig{}, 0},
+		{FilterConfig{Window: Duration(time.Hour), BurstWindow: Duration(time.Minute)}, time.Hour},
+		{FilterConfig{Window: Duration(time.Minute), BurstWindow: Duration(2 * time.Hour)}, 2 * time.Hour},
+	} {
+		if got := tc.f.lookback(); got != tc.want {
+			t.Errorf("lookback(%+v) = %s, want %s", tc.f, got, tc.want)
+		}
+	}
+}
+
+func TestTrailingStart(t *testing.T) {
+	m := at(7.5)
+	if got := trailingStart(m, time.Hour, 5*time.Minute); !got.Equal(m.Add(-time.Hour)) {
+		t.Errorf("with a window: %s", got)
+	}
+	if got := trailingStart(m, 0, 5*time.Minute); !got.Equal(at(5)) {
+		t.Errorf("without a window: %s, want the bucket start", got)
+	}
+}
+
+func TestOutlierScale(t *testing.T) {
+	centre, spread := outlierScale("mad", []float64{1, 2, 3, 4, 100})
+	if centre != 3 || math.Abs(spread-1.4826) > 1e-9 {
+		t.Errorf("mad = %v, %v; want 3, 1.4826", centre, spread)
+	}
+	centre, spread = outlierScale("zscore", []float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if centre != 5 || spread != 2 {
+		t.Errorf("zscore = %v, %v; want 5, 2", centre, spread)
+	}
+	if m := median([]float64{4, 1, 3, 2}); m != 2.5 {
+		t.Errorf("median of an even count = %v, want 2.5", m)
+	}
+}
@@ -0,0 +1,190 @@
+package aggregate
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// scoreEpsilon is the smallest score difference a recompute treats as a
+// change rather than floating-point noise.
+const scoreEpsilon = 1e-9
+
+// Change describes one stored window that a recompute replaced.
+type Change struct {
+	Coin       string    `json:"coin"`
+	Resolution string    `json:"resolution"`
+	Window     time.Time `json:"window"`
+	Revision   int       `json:"revision"`
+	// OldScore is nil when the window had not been stored before.
+	OldScore *float64 `json:"old_score"`
+	NewScore float64  `json:"new_score"`
+	OldCount int      `json:"old_count"`
+	NewCount int      `json:"new_count"`
+}
+
+// RecomputeReport summarises a Recompute run.
+type RecomputeReport struct {
+	Strategy string    `json:"strategy"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	// Checked counts the windows computed per resolution.
+	Checked map[string]int `json:"checked"`
+	Changes []Change       `json:"changes"`
+}
+
+// Recompute computes every resolution again for coins over [start, end)
+// and replaces the stored windows whose score, message count or weight
+// profile differs, keeping the previous rows in the history table, and
+// runs the anomaly detectors over the replaced windows again.
+// Coarser levels are widened to whole windows, up to the last closed
+// one, so they roll up the corrected finer rows. Past end, each coin's
+// carried-forward rows are recomputed too, up to its next fresh row (at
+// most maxCatchUp on), since they continue the scores that changed.
+// opts.Resolution is ignored, and opts must not be Overridden: the
+// replaced windows are the stored series the scheduler goes on with.
+func Recompute(coins []Coin, start, end time.Time, opts Options) (RecomputeReport, error) {
+	if opts.Overridden() {
+		return RecomputeReport{}, fmt.Errorf("recompute cannot override how scores are computed")
+	}
+	agg, err := model.LookupAggregator(opts.Strategy)
+	if err != nil {
+		return RecomputeReport{}, err
+	}
+	report := RecomputeReport{
+		Strategy: agg.Name(),
+		Start:    start.UTC(),
+		End:      end.UTC(),
+		Checked:  map[string]int{},
+		Changes:  []Change{},
+	}
+	codes := make(map[int]string, len(coins))
+	coinIDs := make([]int, 0, len(coins))
+	for _, c := range coins {
+		codes[c.ID] = c.Code
+		coinIDs = append(coinIDs, c.ID)
+	}
+	now := time.Now().UTC().Add(-settleDelay)
+
+	for _, res := range model.Resolutions {
+		from := start.UTC().Truncate(res.Step)
+		to := ceilTime(end.UTC(), res.Step)
+		closed := now.Truncate(res.Step)
+		if to.After(closed) {
+			to = closed
+		}
+		if !from.Before(to) {
+			continue
+		}
+		series := db.Series{Strategy: agg.Name(), Resolution: res.Name}
+
+		// 1) Find how far each coin's carried rows run past the range
+		limit := to.Add(maxCatchUp)
+		if limit.After(closed) {
+			limit = closed
+		}
+		carryUntil, err := db.FetchNextFreshWindows(coinIDs, series, to, limit)
+		if err != nil {
+			return report, fmt.Errorf("%s fetch next fresh: %w", res.Name, err)
+		}
+		until := to
+		for _, id := range coinIDs {
+			if _, ok := carryUntil[id]; !ok {
+				carryUntil[id] = limit
+			}
+			if carryUntil[id].After(until) {
+				until = carryUntil[id]
+			}
+		}
+
+		// 2) Compute again and keep what differs from the stored rows
+		levelOpts := opts
+		levelOpts.Resolution = res.Name
+		_, records, err := Compute(coins, from, until, levelOpts)
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", res.Name, err)
+		}
+		stored, err := db.FetchAggregatedSentimentsBetween(from, until, series)
+		if err != nil {
+			return report, fmt.Errorf("%s fetch stored: %w", res.Name, err)
+		}
+		changed, changes, checked := replacements(records, stored, to, carryUntil, codes, res.Name)
+		report.Changes = append(report.Changes, changes...)
+
+		// 3) Replace them and detect events on them afresh
+		if err := db.ReplaceAggregatedSentimentBatch(changed); err != nil {
+			return report, fmt.Errorf("%s replace: %w", res.Name, err)
+		}
+		if _, err := db.DeleteSentimentEvents(changed); err != nil {
+			return report, fmt.Errorf("%s delete events: %w", res.Name, err)
+		}
+		detect("[Aggregate]", res, changed)
+		report.Checked[res.Name] = checked
+		log.Printf("[Aggregate] recomputed %d %s records, %d changed", checked, res.Name, len(changed))
+	}
+	return report, nil
+}
+
+// replacements picks the recomputed records of one level that replace a
+// stored row or fill a missing one, with a Change for each. Records at
+// or past to are only checked up to their coin's carryUntil, where its
+// next fresh row starts. checked counts the records compared.
+func replacements(records, stored []db.AggregatedSentiment, to time.Time, carryUntil map[int]time.Time,
+	codes map[int]string, res string) (changed []db.AggregatedSentiment, changes []Change, checked int) {
+	type key struct {
+		coin int
+		t    time.Time
+	}
+	old := make(map[key]db.AggregatedSentiment, len(stored))
+	for _, a := range stored {
+		old[key{a.CurrencyID, a.WindowStart.UTC()}] = a
+	}
+
+	for _, rec := range records {
+		if !rec.WindowStart.Before(to) && !rec.WindowStart.Before(carryUntil[rec.CurrencyID]) {
+			continue // past the coin's next fresh row
+		}
+		checked++
+		prev, ok := old[key{rec.CurrencyID, rec.WindowStart}]
+		if ok && !differs(prev, rec) {
+			continue
+		}
+		c := Change{
+			Coin:       codes[rec.CurrencyID],
+			Resolution: res,
+			Window:     rec.WindowStart,
+			Revision:   1,
+			NewScore:   rec.SentimentScore,
+			NewCount:   rec.Stats.Count,
+		}
+		if ok {
+			score := prev.SentimentScore
+			c.OldScore = &score
+			c.OldCount = prev.Stats.Count
+			c.Revision = prev.Revision + 1
+		}
+		changes = append(changes, c)
+		changed = append(changed, rec)
+	}
+	return changed, changes, checked
+}
+
+// differs reports whether a recomputed row should replace the stored one.
+func differs(stored, fresh db.AggregatedSentiment) bool {
+	return math.Abs(stored.SentimentScore-fresh.SentimentScore) > scoreEpsilon ||
+		stored.Stats.Count != fresh.Stats.Count ||
+		stored.Profile != fresh.Profile
+}
+
+// ceilTime rounds t up to a multiple of step.
+func ceilTime(t time.Time, step time.Duration) time.Time {
+	f := t.Truncate(step)
+	if f.Equal(t) {
+		return t
+	}
+	return f.Add(step)
+}
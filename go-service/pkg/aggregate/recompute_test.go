@@ -0,0 +1,89 @@
+package aggregate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+func TestReplacements(t *testing.T) {
+	revised := func(a db.AggregatedSentiment, rev int) db.AggregatedSentiment {
+		a.Revision = rev
+		return a
+	}
+	stored := []db.AggregatedSentiment{
+		revised(row(1, 0, 0.1, 1), 1),
+		revised(row(1, 1, 0.2, 1), 2),
+		revised(row(1, 2, 0.3, 2), 1),
+	}
+	records := []db.AggregatedSentiment{
+		row(1, 0, 0.1+scoreEpsilon/2, 1), // the same up to noise
+		row(1, 1, 0.25, 1),               // a new score
+		row(1, 2, 0.3, 3),                // a new count
+		row(1, 3, 0.4, 0),                // not stored before
+		row(1, 4, 0.4, 0),                // carried past the range, up to coin 1's next fresh row
+		row(1, 5, 0.4, 0),                // that row and past it
+		row(2, 4, 0.5, 0),                // coin 2 has no carried rows to check
+	}
+	carryUntil := map[int]time.Time{1: at(5)}
+	codes := map[int]string{1: "BTC", 2: "ETH"}
+
+	changed, changes, checked := replacements(records, stored, at(4), carryUntil, codes, "1m")
+	if checked != 5 {
+		t.Errorf("checked = %d, want 5", checked)
+	}
+	if got, want := scores(changed), []float64{10.25, 10.3, 10.4, 10.4}; !sameScores(got, want) {
+		t.Errorf("changed = %v, want %v", got, want)
+	}
+	old1, old2 := 0.2, 0.3
+	want := []Change{
+		{Coin: "BTC", Resolution: "1m", Window: at(1), Revision: 3, OldScore: &old1, NewScore: 0.25, OldCount: 1, NewCount: 1},
+		{Coin: "BTC", Resolution: "1m", Window: at(2), Revision: 2, OldScore: &old2, NewScore: 0.3, OldCount: 2, NewCount: 3},
+		{Coin: "BTC", Resolution: "1m", Window: at(3), Revision: 1, NewScore: 0.4},
+		{Coin: "BTC", Resolution: "1m", Window: at(4), Revision: 1, NewScore: 0.4},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+}
+
+func TestDiffers(t *testing.T) {
+	base := row(1, 0, 0.5, 2)
+	base.Profile = "default"
+	for _, tc := range []struct {
+		name string
+		edit func(a *db.AggregatedSentiment)
+		want bool
+	}{
+		{"same", func(a *db.AggregatedSentiment) {}, false},
+		{"noise", func(a *db.AggregatedSentiment) { a.SentimentScore += scoreEpsilon / 2 }, false},
+		{"score", func(a *db.AggregatedSentiment) { a.SentimentScore += 0.01 }, true},
+		{"count", func(a *db.AggregatedSentiment) { a.Stats.Count++ }, true},
+		{"profile", func(a *db.AggregatedSentiment) { a.Profile = "alt" }, true},
+	} {
+		fresh := base
+		tc.edit(&fresh)
+		if got := differs(base, fresh); got != tc.want {
+			t.Errorf("%s: differs = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCeilTime(t *testing.T) {
+	if got := ceilTime(at(7), 5*time.Minute); !got.Equal(at(10)) {
+		t.Errorf("ceilTime(7m) = %s, want 10m", got)
+	}
+	if got := ceilTime(at(10), 5*time.Minute); !got.Equal(at(10)) {
+		t.Errorf("ceilTime(10m) = %s, want 10m", got)
+	}
+}
+
+func TestRecomputeRefusesOverrides(t *testing.T) {
+	// refused before anything reads or writes the database
+	_, err := Recompute(btc, at(0), at(5), Options{Decay: &DecayConfig{HalfLife: Duration(time.Minute)}})
+	if err == nil {
+		t.Fatal("recompute with a decay override succeeded")
+	}
+}
@@ -0,0 +1,280 @@
+package aggregate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/events"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+const (
+	// settleDelay gives late-arriving raw messages a moment to land
+	// before a just-closed window is computed.
+	settleDelay = 30 * time.Second
+
+	// maxCatchUp bounds how far back the scheduler backfills after a restart.
+	maxCatchUp = 24 * time.Hour
+
+	// chunkWindows is how many windows are computed per query.
+	chunkWindows = 60
+)
+
+// Status reports what the background scheduler has done so far.
+type Status struct {
+	Running bool `json:"running"`
+	// LastWindows holds the last window stored per series, keyed by
+	// seriesKey.
+	LastWindows    map[string]time.Time `json:"last_windows"`
+	LastRun        time.Time            `json:"last_run"`
+	NextRun        time.Time            `json:"next_run"`
+	WindowsWritten int                  `json:"windows_written"`
+	LastError      string               `json:"last_error,omitempty"`
+	// Dropped totals, per filter rule, the raw messages removed from the
+	// base windows the scheduler has stored since start.
+	Dropped map[string]int `json:"dropped"`
+}
+
+var (
+	statusMu sync.Mutex
+	status   = Status{LastWindows: map[string]time.Time{}, Dropped: map[string]int{}}
+)
+
+// CurrentStatus returns a snapshot of the scheduler's progress.
+func CurrentStatus() Status {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	out := status
+	out.LastWindows = make(map[string]time.Time, len(status.LastWindows))
+	for k, v := range status.LastWindows {
+		out.LastWindows[k] = v
+	}
+	out.Dropped = make(map[string]int, len(status.Dropped))
+	for k, v := range status.Dropped {
+		out.Dropped[k] = v
+	}
+	return out
+}
+
+// recordDropped adds the filter drops of stored base-level records to
+// Status.Dropped. Coarser rows only repeat the drops of the rows they
+// roll up.
+func recordDropped(records []db.AggregatedSentiment) {
+	updateStatus(func(s *Status) {
+		for _, r := range records {
+			if r.Resolution != model.Resolutions[0].Name {
+				continue
+			}
+			for rule, n := range r.Stats.Dropped {
+				s.Dropped[rule] += n
+			}
+		}
+	})
+}
+
+func updateStatus(fn func(s *Status)) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	fn(&status)
+}
+
+// scheduled lists the strategies the scheduler computes, in order.
+var scheduled = []string{model.DefaultStrategy}
+
+// LoadStrategies validates the comma-separated strategy names in list and
+// installs them as the ones the scheduler computes. The list must include
+// model.DefaultStrategy, which requests that name no strategy read; an
+// empty list selects it alone.
+func LoadStrategies(list string) error {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		agg, err := model.LookupAggregator(name)
+		if err != nil {
+			return err
+		}
+		if !containsName(names, agg.Name()) {
+			names = append(names, agg.Name())
+		}
+	}
+	if len(names) == 0 {
+		names = []string{model.DefaultStrategy}
+	}
+	if !containsName(names, model.DefaultStrategy) {
+		return fmt.Errorf("strategies %s leave out the default strategy %q",
+			strings.Join(names, ", "), model.DefaultStrategy)
+	}
+	scheduled = names
+	return nil
+}
+
+// CheckScheduled reports an error unless the scheduler keeps strategy
+// (empty for the default) up to date. Streaming a strategy it does not
+// compute would only ever show gaps.
+func CheckScheduled(strategy string) error {
+	if strategy == "" {
+		strategy = model.DefaultStrategy
+	}
+	if !containsName(scheduled, strategy) {
+		return fmt.Errorf("strategy %q is not aggregated by the scheduler (scheduled: %s)",
+			strategy, strings.Join(scheduled, ", "))
+	}
+	return nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StartScheduler computes every closed window, at every resolution and
+// for every scheduled strategy, for the coins returned by coins as soon as
+// it ends. The base resolution is computed first so coarser windows can be
+// rolled up from it. On start it catches up on windows missed while the
+// service was down (bounded by maxCatchUp). It runs until ctx is done.
+func StartScheduler(ctx context.Context, coins func() []Coin) {
+	updateStatus(func(s *Status) { s.Running = true })
+	strategies := scheduled
+	go func() {
+		defer updateStatus(func(s *Status) { s.Running = false })
+
+		base := model.Resolutions[0]
+		next := make(map[string]time.Time, len(strategies)*len(model.Resolutions))
+		for {
+			now := time.Now().UTC().Add(-settleDelay)
+			current := coins()
+			for _, strategy := range strategies {
+				for _, res := range model.Resolutions {
+					series := db.Series{Strategy: strategy, Resolution: res.Name}
+					key := seriesKey(series)
+					closed := now.Truncate(res.Step)
+					if next[key].IsZero() {
+						from, err := resumePoint(series, res, closed)
+						if err != nil {
+							log.Printf("[Scheduler] %v; retrying next run", err)
+							updateStatus(func(s *Status) {
+								s.LastRun = time.Now().UTC()
+								s.LastError = err.Error()
+							})
+							break
+						}
+						next[key] = from
+					}
+					next[key] = runWindows(current, series, res, next[key], closed)
+					if next[key].Before(closed) {
+						// coarser levels would roll up the missing windows as gaps
+						break
+					}
+				}
+			}
+
+			wake := now.Truncate(base.Step).Add(base.Step).Add(settleDelay)
+			updateStatus(func(s *Status) { s.NextRun = wake })
+			select {
+			case <-ctx.Done():
+				log.Println("[Scheduler] stopped")
+				return
+			case <-time.After(time.Until(wake)):
+			}
+		}
+	}()
+	log.Printf("[Scheduler] aggregation scheduler started for %s", strings.Join(strategies, ", "))
+}
+
+// seriesKey names series in Status.LastWindows, e.g. "weighted_mean/5m".
+func seriesKey(series db.Series) string {
+	return series.Strategy + "/" + series.Resolution
+}
+
+// resumePoint returns the first window of series that still needs computing.
+func resumePoint(series db.Series, res model.Resolution, closed time.Time) (time.Time, error) {
+	latest, err := db.FetchLatestAggregatedWindow(series, closed)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fetch latest %s window: %w", seriesKey(series), err)
+	}
+	earliest := closed.Add(-maxCatchUp).Truncate(res.Step)
+	if latest.IsZero() {
+		// nothing stored yet: start with the most recent closed window
+		return closed.Add(-res.Step), nil
+	}
+	next := latest.Add(res.Step)
+	if next.Before(earliest) {
+		log.Printf("[Scheduler] last %s window %s is older than %s, skipping ahead",
+			seriesKey(series), latest.Format(time.RFC3339), maxCatchUp)
+		return earliest, nil
+	}
+	return next, nil
+}
+
+// runWindows computes and stores every res window in [from, closed), runs
+// the anomaly detectors over the ones it inserted and returns the first window that is
+// still outstanding. With no coins (all disabled) there is nothing to
+// store and every window counts as done.
+func runWindows(coins []Coin, series db.Series, res model.Resolution, from, closed time.Time) time.Time {
+	if len(coins) == 0 && from.Before(closed) {
+		return closed
+	}
+	opts := Options{Strategy: series.Strategy, Resolution: res.Name}
+	for from.Before(closed) {
+		to := from.Add(chunkWindows * res.Step)
+		if to.After(closed) {
+			to = closed
+		}
+
+		buckets, records, err := Compute(coins, from, to, opts)
+		var inserted []db.AggregatedSentiment
+		if err == nil {
+			inserted, err = db.InsertAggregatedSentimentBatch(records)
+		}
+		if err != nil {
+			log.Printf("[Scheduler] %s window %s → %s failed: %v", seriesKey(series),
+				from.Format(time.RFC3339), to.Format(time.RFC3339), err)
+			updateStatus(func(s *Status) {
+				s.LastRun = time.Now().UTC()
+				s.LastError = err.Error()
+			})
+			return from
+		}
+
+		recordDropped(inserted)
+		log.Printf("[Scheduler] stored %d %s windows (%d of %d records new) %s → %s",
+			len(buckets), seriesKey(series), len(inserted), len(records),
+			from.Format(time.RFC3339), to.Format(time.RFC3339))
+		detect("[Scheduler]", res, inserted)
+		last := to.Add(-res.Step)
+		updateStatus(func(s *Status) {
+			s.LastRun = time.Now().UTC()
+			s.LastWindows[seriesKey(series)] = last
+			s.WindowsWritten += len(buckets)
+			s.LastError = ""
+		})
+		from = to
+	}
+	return from
+}
+
+// detect runs the anomaly detectors over rows, which must have just been
+// written to one series at res, so events always match the stored
+// aggregates. tag prefixes the log lines.
+func detect(tag string, res model.Resolution, rows []db.AggregatedSentiment) {
+	if len(rows) == 0 {
+		return
+	}
+	key := seriesKey(db.Series{Strategy: rows[0].Strategy, Resolution: rows[0].Resolution})
+	if found, err := events.Process(events.DefaultConfig, res, rows); err != nil {
+		log.Printf("%s %s event detection failed: %v", tag, key, err)
+	} else if len(found) > 0 {
+		log.Printf("%s detected %d %s events", tag, len(found), key)
+	}
+}
@@ -0,0 +1,83 @@
+package aggregate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+func TestLoadStrategies(t *testing.T) {
+	prev := scheduled
+	t.Cleanup(func() { scheduled = prev })
+
+	for _, tc := range []struct {
+		list string
+		want []string
+		err  string
+	}{
+		{"", []string{"weighted_mean"}, ""},
+		{" , ", []string{"weighted_mean"}, ""},
+		{"trimmed_mean, weighted_mean,trimmed_mean", []string{"trimmed_mean", "weighted_mean"}, ""},
+		{"trimmed_mean", nil, `leave out the default strategy "weighted_mean"`},
+		{"weighted_mean,nope", nil, "nope"},
+	} {
+		scheduled = prev
+		err := LoadStrategies(tc.list)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("LoadStrategies(%q): err = %v, want %q", tc.list, err, tc.err)
+			}
+			if !reflect.DeepEqual(scheduled, prev) {
+				t.Errorf("LoadStrategies(%q) failed but installed %v", tc.list, scheduled)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(scheduled, tc.want) {
+			t.Errorf("LoadStrategies(%q) = %v, %v; want %v", tc.list, scheduled, err, tc.want)
+		}
+	}
+}
+
+func TestCheckScheduled(t *testing.T) {
+	prev := scheduled
+	t.Cleanup(func() { scheduled = prev })
+	scheduled = []string{"weighted_mean", "trimmed_mean"}
+
+	for strategy, ok := range map[string]bool{
+		"":                true,
+		"weighted_mean":   true,
+		"trimmed_mean":    true,
+		"weighted_median": false,
+	} {
+		if err := CheckScheduled(strategy); (err == nil) != ok {
+			t.Errorf("CheckScheduled(%q) = %v, want ok %v", strategy, err, ok)
+		}
+	}
+}
+
+// With every coin disabled the scheduler must not query the database:
+// db.Conn is nil here, so any query would panic.
+func TestSchedulerWithoutCoins(t *testing.T) {
+	res, _ := model.LookupResolution("5m")
+	series := db.Series{Strategy: model.DefaultStrategy, Resolution: res.Name}
+	closed := testStart.Add(time.Hour)
+
+	if next := runWindows(nil, series, res, testStart, closed); !next.Equal(closed) {
+		t.Errorf("runWindows without coins = %s, want %s", next, closed)
+	}
+	if next := runWindows(nil, series, res, closed, closed); !next.Equal(closed) {
+		t.Errorf("runWindows with nothing to do = %s, want %s", next, closed)
+	}
+	last, err := dbStore{}.LastScores(nil, series, closed)
+	if err != nil || len(last) != 0 {
+		t.Errorf("LastScores without coins = %v, %v; want none", last, err)
+	}
+	fresh, err := db.FetchNextFreshWindows(nil, series, testStart, closed)
+	if err != nil || len(fresh) != 0 {
+		t.Errorf("FetchNextFreshWindows without coins = %v, %v; want none", fresh, err)
+	}
+}
@@ -0,0 +1,69 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// SourceWeights scales each message by the trust placed in its source
+// (raw_messages.source: twitter, reddit, news, …). Sources missing from
+// both maps weigh 1.
+type SourceWeights struct {
+	// Default applies to every coin.
+	Default map[string]float64 `json:"default"`
+	// Coins overrides Default per coin code, source by source.
+	Coins map[string]map[string]float64 `json:"coins"`
+}
+
+// DefaultSourceWeights is applied when a run does not override them.
+var DefaultSourceWeights SourceWeights
+
+// Validate rejects negative weights.
+func (sw SourceWeights) Validate() error {
+	for src, w := range sw.Default {
+		if w < 0 {
+			return fmt.Errorf("default weight for %q is negative", src)
+		}
+	}
+	for code, m := range sw.Coins {
+		for src, w := range m {
+			if w < 0 {
+				return fmt.Errorf("%s weight for %q is negative", code, src)
+			}
+		}
+	}
+	return nil
+}
+
+// Weight returns the weight of source for the coin with the given code.
+func (sw SourceWeights) Weight(code, source string) float64 {
+	if w, ok := sw.Coins[code][source]; ok {
+		return w
+	}
+	if w, ok := sw.Default[source]; ok {
+		return w
+	}
+	return 1
+}
+
+// LoadSourceWeights fills DefaultSourceWeights from the JSON file at path.
+// Without one every source weighs 1.
+func LoadSourceWeights(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	var sw SourceWeights
+	if err := json.Unmarshal(data, &sw); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := sw.Validate(); err != nil {
+		return err
+	}
+	DefaultSourceWeights = sw
+	return nil
+}
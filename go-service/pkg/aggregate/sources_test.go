@@ -0,0 +1,39 @@
+package aggregate
+
+import "testing"
+
+func TestSourceWeight(t *testing.T) {
+	sw := SourceWeights{
+		Default: map[string]float64{"reddit": 0.5, "news": 2},
+		Coins:   map[string]map[string]float64{"BTC": {"reddit": 0}},
+	}
+	for _, tc := range []struct {
+		code, source string
+		want         float64
+	}{
+		{"BTC", "reddit", 0}, // the coin's own weight wins
+		{"BTC", "news", 2},   // then the default
+		{"ETH", "reddit", 0.5},
+		{"ETH", "telegram", 1}, // unlisted sources weigh 1
+	} {
+		if got := sw.Weight(tc.code, tc.source); got != tc.want {
+			t.Errorf("Weight(%s, %s) = %v, want %v", tc.code, tc.source, got, tc.want)
+		}
+	}
+}
+
+func TestSourceWeightsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		sw SourceWeights
+		ok bool
+	}{
+		{SourceWeights{}, true},
+		{SourceWeights{Default: map[string]float64{"reddit": 0}}, true},
+		{SourceWeights{Default: map[string]float64{"reddit": -1}}, false},
+		{SourceWeights{Coins: map[string]map[string]float64{"BTC": {"news": -0.5}}}, false},
+	} {
+		if err := tc.sw.Validate(); (err == nil) != tc.ok {
+			t.Errorf("Validate(%+v) = %v, want ok %v", tc.sw, err, tc.ok)
+		}
+	}
+}
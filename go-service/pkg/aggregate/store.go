@@ -0,0 +1,174 @@
+package aggregate
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+// Store supplies the stored rows a Compute run builds on: the finer rows
+// a rollup combines and the last scores a carry-forward continues from.
+type Store interface {
+	// AggregatedBetween returns the rows in series whose window start is
+	// in [start, end], oldest first.
+	AggregatedBetween(start, end time.Time, series db.Series) ([]db.AggregatedSentiment, error)
+	// LastScores returns the latest score before the given time, with its
+	// window, of each coin in coinIDs that has one.
+	LastScores(coinIDs []int, series db.Series, before time.Time) (map[int]db.LastScore, error)
+}
+
+// MessageSource supplies the raw messages the base resolution is
+// computed from.
+type MessageSource interface {
+	// RawBetween returns the scored messages created in [start, end),
+	// oldest first.
+	RawBetween(start, end time.Time) ([]db.MessageScore, error)
+}
+
+// dbStore reads the aggregated_sentiments and raw_messages tables.
+type dbStore struct{}
+
+func (dbStore) RawBetween(start, end time.Time) ([]db.MessageScore, error) {
+	return db.FetchRawMessagesBetween(start, end)
+}
+
+func (dbStore) AggregatedBetween(start, end time.Time, series db.Series) ([]db.AggregatedSentiment, error) {
+	return db.FetchAggregatedSentimentsBetween(start, end, series)
+}
+
+func (dbStore) LastScores(coinIDs []int, series db.Series, before time.Time) (map[int]db.LastScore, error) {
+	return db.FetchInitialLastSentiments(coinIDs, series, before)
+}
+
+// MemoryStore keeps computed rows in memory, so a run can build on its
+// own results without reading or writing aggregated_sentiments. It is not
+// safe for concurrent use.
+type MemoryStore struct {
+	rows map[db.Series]map[int][]db.AggregatedSentiment // oldest first
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{rows: map[db.Series]map[int][]db.AggregatedSentiment{}}
+}
+
+// Add stores records, replacing any row already held for the same coin,
+// series and window.
+func (m *MemoryStore) Add(records []db.AggregatedSentiment) {
+	touched := map[db.Series]map[int]bool{}
+	for _, r := range records {
+		s := db.Series{Strategy: r.Strategy, Resolution: r.Resolution}
+		if m.rows[s] == nil {
+			m.rows[s] = map[int][]db.AggregatedSentiment{}
+		}
+		if touched[s] == nil {
+			touched[s] = map[int]bool{}
+		}
+		m.rows[s][r.CurrencyID] = append(m.rows[s][r.CurrencyID], r)
+		touched[s][r.CurrencyID] = true
+	}
+	for s, coins := range touched {
+		for id := range coins {
+			m.rows[s][id] = dedupeByWindow(m.rows[s][id])
+		}
+	}
+}
+
+// dedupeByWindow sorts rows oldest first and keeps the last-added row of
+// each window.
+func dedupeByWindow(rows []db.AggregatedSentiment) []db.AggregatedSentiment {
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].WindowStart.Before(rows[j].WindowStart) })
+	out := rows[:0]
+	for _, r := range rows {
+		if n := len(out); n > 0 && out[n-1].WindowStart.Equal(r.WindowStart) {
+			out[n-1] = r
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// Prune drops the rows whose window starts before the given time, except
+// the latest one per coin and series, which later carry-forwards need.
+func (m *MemoryStore) Prune(before time.Time) {
+	for _, coins := range m.rows {
+		for id, rows := range coins {
+			i := sort.Search(len(rows), func(i int) bool { return !rows[i].WindowStart.Before(before) })
+			if i > 1 {
+				coins[id] = append([]db.AggregatedSentiment(nil), rows[i-1:]...)
+			}
+		}
+	}
+}
+
+func (m *MemoryStore) AggregatedBetween(start, end time.Time, series db.Series) ([]db.AggregatedSentiment, error) {
+	var out []db.AggregatedSentiment
+	for _, rows := range m.rows[series] {
+		for _, r := range rows {
+			if !r.WindowStart.Before(start) && !r.WindowStart.After(end) {
+				out = append(out, r)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].WindowStart.Before(out[j].WindowStart) })
+	return out, nil
+}
+
+func (m *MemoryStore) LastScores(coinIDs []int, series db.Series, before time.Time) (map[int]db.LastScore, error) {
+	out := make(map[int]db.LastScore, len(coinIDs))
+	for _, id := range coinIDs {
+		rows := m.rows[series][id]
+		i := sort.Search(len(rows), func(i int) bool { return !rows[i].WindowStart.Before(before) })
+		if i > 0 {
+			out[id] = db.LastScore{WindowStart: rows[i-1].WindowStart, Score: rows[i-1].SentimentScore}
+		}
+	}
+	return out, nil
+}
+
+// overlayStore reads the rows held in mem in front of those in base, so a
+// run can build on results it did not store.
+type overlayStore struct {
+	base Store
+	mem  *MemoryStore
+}
+
+func (o overlayStore) AggregatedBetween(start, end time.Time, series db.Series) ([]db.AggregatedSentiment, error) {
+	stored, err := o.base.AggregatedBetween(start, end, series)
+	if err != nil {
+		return nil, err
+	}
+	held, _ := o.mem.AggregatedBetween(start, end, series)
+	type rowKey struct {
+		coin   int
+		window time.Time
+	}
+	shadowed := make(map[rowKey]bool, len(held))
+	for _, r := range held {
+		shadowed[rowKey{r.CurrencyID, r.WindowStart}] = true
+	}
+	out := held
+	for _, r := range stored {
+		if !shadowed[rowKey{r.CurrencyID, r.WindowStart}] {
+			out = append(out, r)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].WindowStart.Before(out[j].WindowStart) })
+	return out, nil
+}
+
+func (o overlayStore) LastScores(coinIDs []int, series db.Series, before time.Time) (map[int]db.LastScore, error) {
+	out, err := o.base.LastScores(coinIDs, series, before)
+	if err != nil {
+		return nil, err
+	}
+	held, _ := o.mem.LastScores(coinIDs, series, before)
+	for id, last := range held {
+		if stored, ok := out[id]; !ok || !last.WindowStart.Before(stored.WindowStart) {
+			out[id] = last
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,123 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+var testSeries = db.Series{Strategy: model.DefaultStrategy, Resolution: "1m"}
+
+// row is a stored 1m row of coin with score s and count messages at m
+// minutes.
+func row(coin int, m float64, s float64, count int) db.AggregatedSentiment {
+	return db.AggregatedSentiment{
+		CurrencyID:     coin,
+		WindowStart:    at(m),
+		SentimentScore: s,
+		Strategy:       testSeries.Strategy,
+		Resolution:     testSeries.Resolution,
+		Stats:          model.BucketStats{Count: count},
+	}
+}
+
+// scores lists the coin and score of rows, for comparing in tests.
+func scores(rows []db.AggregatedSentiment) []float64 {
+	out := make([]float64, len(rows))
+	for i, r := range rows {
+		out[i] = float64(r.CurrencyID)*10 + r.SentimentScore
+	}
+	return out
+}
+
+func sameScores(got, want []float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if !near(got[i], want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryStore(t *testing.T) {
+	m := NewMemoryStore()
+	m.Add([]db.AggregatedSentiment{row(1, 2, 0.2, 1), row(1, 0, 0.1, 1), row(1, 1, 0.15, 1), row(2, 1.5, 0.5, 1)})
+	m.Add([]db.AggregatedSentiment{row(1, 2, 0.3, 1)}) // replaces the row at 2
+	other := row(1, 1, 0.9, 1)
+	other.Resolution = "5m"
+	m.Add([]db.AggregatedSentiment{other})
+
+	rows, _ := m.AggregatedBetween(at(0), at(2), testSeries) // both ends included
+	if want := []float64{10.1, 10.15, 20.5, 10.3}; !sameScores(scores(rows), want) {
+		t.Errorf("AggregatedBetween = %v, want %v", scores(rows), want)
+	}
+	if rows, _ := m.AggregatedBetween(at(0.5), at(1.5), testSeries); !sameScores(scores(rows), []float64{10.15, 20.5}) {
+		t.Errorf("AggregatedBetween inside = %v, want [10.15 20.5]", scores(rows))
+	}
+
+	last, _ := m.LastScores([]int{1, 2, 3}, testSeries, at(2)) // strictly before
+	if len(last) != 2 || last[1].Score != 0.15 || !last[1].WindowStart.Equal(at(1)) || last[2].Score != 0.5 {
+		t.Errorf("LastScores = %+v, want coin 1 at 1 and coin 2 at 1.5", last)
+	}
+
+	m.Prune(at(2))
+	rows, _ = m.AggregatedBetween(at(0), at(2), testSeries)
+	// the latest row before the cut stays for carry-forwards
+	if want := []float64{10.15, 20.5, 10.3}; !sameScores(scores(rows), want) {
+		t.Errorf("after Prune = %v, want %v", scores(rows), want)
+	}
+}
+
+// fixedStore answers every read with the same rows and last scores,
+// standing in for the database.
+type fixedStore struct {
+	rows []db.AggregatedSentiment
+	last map[int]db.LastScore
+}
+
+func (f fixedStore) AggregatedBetween(start, end time.Time, series db.Series) ([]db.AggregatedSentiment, error) {
+	return f.rows, nil
+}
+
+func (f fixedStore) LastScores(coinIDs []int, series db.Series, before time.Time) (map[int]db.LastScore, error) {
+	out := make(map[int]db.LastScore, len(f.last))
+	for id, l := range f.last {
+		out[id] = l
+	}
+	return out, nil
+}
+
+func TestOverlayStore(t *testing.T) {
+	base := fixedStore{
+		rows: []db.AggregatedSentiment{row(1, 0, 0.1, 1), row(1, 1, 0.2, 1), row(2, 1, 0.4, 1)},
+		last: map[int]db.LastScore{
+			1: {WindowStart: at(1), Score: 0.2},
+			2: {WindowStart: at(3), Score: 0.4},
+		},
+	}
+	mem := NewMemoryStore()
+	mem.Add([]db.AggregatedSentiment{row(1, 1, 0.7, 1), row(1, 2, 0.8, 1)}) // open windows
+	o := overlayStore{base: base, mem: mem}
+
+	rows, err := o.AggregatedBetween(at(0), at(2), testSeries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the held row at 1 shadows the stored one
+	if want := []float64{10.1, 10.7, 20.4, 10.8}; !sameScores(scores(rows), want) {
+		t.Errorf("AggregatedBetween = %v, want %v", scores(rows), want)
+	}
+
+	last, _ := o.LastScores([]int{1, 2}, testSeries, at(3))
+	if last[1].Score != 0.8 || !last[1].WindowStart.Equal(at(2)) {
+		t.Errorf("coin 1 last = %+v, want the later held row", last[1])
+	}
+	if last[2].Score != 0.4 {
+		t.Errorf("coin 2 last = %+v, want the stored row", last[2])
+	}
+}
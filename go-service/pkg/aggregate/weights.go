@@ -0,0 +1,149 @@
+package aggregate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// reloadMu serialises weight reloads so two of them cannot interleave
+// their read and install steps.
+var reloadMu sync.Mutex
+
+// WeightsSource names where ReloadWeights reads from: "db" for the
+// question_weights and coin_weight_profiles tables, "file" for
+// the weights file, or "builtin" for model.DefaultWeights. Weights read from
+// the database or a file are keyed by question ID (questions.id).
+func WeightsSource() string {
+	switch s := config.Current.Weights; {
+	case s.Source == "db":
+		return "db"
+	case s.File != "":
+		return "file"
+	default:
+		return "builtin"
+	}
+}
+
+// ReloadWeights reads the weight profiles (weights keyed by question ID)
+// from WeightsSource, validates them against the loaded question mapping
+// and installs them atomically. On any error the profiles in effect stay
+// unchanged. Runs already in progress keep the set they started with.
+func ReloadWeights() (model.WeightProfiles, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	var p model.WeightProfiles
+	switch WeightsSource() {
+	case "db":
+		profiles, coins, err := db.FetchWeightProfiles()
+		if err != nil {
+			return p, fmt.Errorf("fetch weight profiles: %w", err)
+		}
+		p = model.WeightProfiles{Profiles: profiles, Coins: coins}
+	case "file":
+		var err error
+		if p, err = ReadWeightsFile(config.Current.Weights.File); err != nil {
+			return p, err
+		}
+	default:
+		var err error
+		if p, err = builtinProfiles(); err != nil {
+			return p, err
+		}
+	}
+	if err := p.Validate(config.QuestionMapping); err != nil {
+		return p, err
+	}
+	for _, q := range config.Questions {
+		for name, w := range p.Profiles {
+			if _, ok := w[q.ID]; !ok {
+				log.Printf("[Weights] question %s (code %s) has no weight in profile %q; its messages are ignored",
+					q.ID, q.Code, name)
+			}
+		}
+	}
+	model.SetProfiles(p)
+	log.Printf("[Weights] loaded %d weight profiles (%d coin assignments) from %s",
+		len(p.Profiles), len(p.Coins), WeightsSource())
+	return p, nil
+}
+
+// builtinProfiles re-keys model.DefaultWeights from question codes to the
+// registry's IDs. Without a registry the codes are used as they are.
+func builtinProfiles() (model.WeightProfiles, error) {
+	if len(config.Questions) == 0 {
+		return model.BuiltinProfiles(), nil
+	}
+	w := make(map[string]float64, len(model.DefaultWeights))
+	for code, v := range model.DefaultWeights {
+		id, ok := config.IDForCode(code)
+		if !ok {
+			return model.WeightProfiles{}, fmt.Errorf("built-in weight for question code %s: no such question", code)
+		}
+		w[id] = v
+	}
+	return model.WeightProfiles{Profiles: map[string]map[string]float64{model.DefaultProfile: w}}, nil
+}
+
+// ReadWeightsFile reads weight profiles from either
+// {"profiles": {...}, "coins": {...}} or, for a single profile, a flat
+// object of question ID → weight. It does not validate them.
+func ReadWeightsFile(path string) (model.WeightProfiles, error) {
+	var p model.WeightProfiles
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &p); err == nil && len(p.Profiles) > 0 {
+		return p, nil
+	}
+	var flat map[string]float64
+	if err := json.Unmarshal(data, &flat); err != nil {
+		return p, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return model.WeightProfiles{Profiles: map[string]map[string]float64{model.DefaultProfile: flat}}, nil
+}
+
+// WatchWeightsFile polls the weights file every interval and reloads the
+// weights when its modification time changes. Invalid edits are logged
+// and skipped. It returns at once unless the weights come from a file,
+// and otherwise runs until ctx is done.
+func WatchWeightsFile(ctx context.Context, interval time.Duration) {
+	if WeightsSource() != "file" {
+		return
+	}
+	path := config.Current.Weights.File
+	var last time.Time
+	if fi, err := os.Stat(path); err == nil {
+		last = fi.ModTime()
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			fi, err := os.Stat(path)
+			if err != nil || fi.ModTime().Equal(last) {
+				continue
+			}
+			last = fi.ModTime()
+			if _, err := ReloadWeights(); err != nil {
+				log.Printf("[Weights] reload of %s rejected: %v", path, err)
+			}
+		}
+	}()
+	log.Printf("[Weights] watching %s every %s", path, interval)
+}
@@ -0,0 +1,100 @@
+// Package coins caches the currency table for the aggregation engine and
+// the HTTP and WebSocket handlers.
+package coins
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+var (
+	mu     sync.RWMutex
+	byID   = map[int]db.Currency{}
+	sorted []db.Currency // ordered by ID
+)
+
+// Refresh reloads the registry from the currency table. Call it after
+// changing coins so every reader sees the change.
+func Refresh() error {
+	list, err := db.ListCurrencies()
+	if err != nil {
+		return err
+	}
+	Load(list)
+	return nil
+}
+
+// Load replaces the registry with list, which must be ordered by ID.
+func Load(list []db.Currency) {
+	ids := make(map[int]db.Currency, len(list))
+	for _, c := range list {
+		ids[c.ID] = c
+	}
+	mu.Lock()
+	byID = ids
+	sorted = list
+	mu.Unlock()
+}
+
+// StartRefresher reloads the registry every interval, picking up coins
+// changed outside this process, until ctx is done.
+func StartRefresher(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := Refresh(); err != nil {
+					log.Printf("[Coins] refresh error: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// All returns every coin, enabled or not, ordered by ID.
+func All() []db.Currency {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]db.Currency(nil), sorted...)
+}
+
+// Enabled returns the enabled coins ordered by ID.
+func Enabled() []db.Currency {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]db.Currency, 0, len(sorted))
+	for _, c := range sorted {
+		if c.Enabled {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// ByID finds a coin by its currency ID.
+func ByID(id int) (db.Currency, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := byID[id]
+	return c, ok
+}
+
+// ByCode finds a coin by its ticker code.
+func ByCode(code string) (db.Currency, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, c := range sorted {
+		if c.Code == code {
+			return c, true
+		}
+	}
+	return db.Currency{}, false
+}
@@ -3,15 +3,86 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 )
 
-// QuestionMapping maps numeric keys → full question text.
+// Question is one entry of the question registry.
+type Question struct {
+	// ID is questions.id, the key raw_messages.question_id refers to.
+	ID   string `json:"id"`
+	Code string `json:"code"`
+	Text string `json:"text"`
+}
+
+// QuestionMapping maps question IDs → full question text.
 var QuestionMapping map[string]string
 
-// LoadQuestionMapping takes the raw JSON bytes of mapping.json.
-func LoadQuestionMapping(raw []byte) error {
-	if err := json.Unmarshal(raw, &QuestionMapping); err != nil {
-		return fmt.Errorf("parse mapping: %w", err)
+// Questions is the question registry, ordered by ID.
+var Questions []Question
+
+// ParseQuestionMapping takes the raw JSON bytes of mapping.json, an
+// object of question code → text.
+func ParseQuestionMapping(raw []byte) (map[string]string, error) {
+	var mapping map[string]string
+	if err := json.Unmarshal(raw, &mapping); err != nil {
+		return nil, fmt.Errorf("parse mapping: %w", err)
+	}
+	return mapping, nil
+}
+
+// SetQuestions installs qs as the question registry and rebuilds
+// QuestionMapping from it.
+func SetQuestions(qs []Question) {
+	sorted := append([]Question(nil), qs...)
+	sort.Slice(sorted, func(i, j int) bool { return lessID(sorted[i].ID, sorted[j].ID) })
+	mapping := make(map[string]string, len(sorted))
+	for _, q := range sorted {
+		mapping[q.ID] = q.Text
+	}
+	Questions = sorted
+	QuestionMapping = mapping
+}
+
+// IDForCode returns the ID of the question with the given code.
+func IDForCode(code string) (string, bool) {
+	for _, q := range Questions {
+		if q.Code == code {
+			return q.ID, true
+		}
+	}
+	return "", false
+}
+
+// CheckQuestionMapping compares a code → text mapping (as in
+// mapping.json) against the registry and describes every disagreement.
+func CheckQuestionMapping(mapping map[string]string) []string {
+	var problems []string
+	byCode := make(map[string]Question, len(Questions))
+	for _, q := range Questions {
+		byCode[q.Code] = q
+		if q.ID != q.Code {
+			problems = append(problems, fmt.Sprintf("question code %s has ID %s", q.Code, q.ID))
+		}
+		if text, ok := mapping[q.Code]; ok && text != q.Text {
+			problems = append(problems, fmt.Sprintf("question code %s: mapping text %q differs from %q", q.Code, text, q.Text))
+		}
+	}
+	for code := range mapping {
+		if _, ok := byCode[code]; !ok {
+			problems = append(problems, fmt.Sprintf("mapping code %s is not in the questions table", code))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// lessID orders numeric IDs numerically and anything else as strings.
+func lessID(a, b string) bool {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return x < y
 	}
-	return nil
+	return a < b
 }
@@ -0,0 +1,347 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings is the whole service configuration. Each value comes from, in
+// rising precedence: the default below, the config file, the environment
+// variable named by its env tag and the command-line flag named after its
+// dotted yaml key (e.g. --server.port). Fields tagged secret are redacted
+// when printed.
+type Settings struct {
+	Server      ServerSettings      `yaml:"server"`
+	Database    DatabaseSettings    `yaml:"database"`
+	OpenAI      OpenAISettings      `yaml:"openai"`
+	Firebase    FirebaseSettings    `yaml:"firebase"`
+	Questions   QuestionSettings    `yaml:"questions"`
+	Coins       CoinSettings        `yaml:"coins"`
+	Aggregation AggregationSettings `yaml:"aggregation"`
+	Filters     FilterSettings      `yaml:"filters"`
+	Decay       DecaySettings       `yaml:"decay"`
+	Authors     AuthorSettings      `yaml:"authors"`
+	Weights     WeightSettings      `yaml:"weights"`
+	Events      EventSettings       `yaml:"events"`
+}
+
+type ServerSettings struct {
+	Port int `yaml:"port" env:"PORT" help:"HTTP listen port"`
+	// AdminToken guards the admin endpoints; empty disables them.
+	AdminToken string `yaml:"admin_token" env:"ADMIN_TOKEN" secret:"true" help:"token for X-Admin-Token"`
+	// StreamInterval is how often live feeds are refetched for streaming clients.
+	StreamInterval time.Duration `yaml:"stream_interval" env:"STREAM_REFRESH_INTERVAL" help:"how often live /ws feeds are refreshed"`
+}
+
+type DatabaseSettings struct {
+	URL             string        `yaml:"url" env:"DATABASE_URL" secret:"true" help:"Postgres connection string"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" help:"connection pool size"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" help:"idle connection lifetime"`
+}
+
+type OpenAISettings struct {
+	APIKey string `yaml:"api_key" env:"OPENAI_API_KEY" secret:"true" help:"OpenAI API key"`
+	Model  string `yaml:"model" env:"OPENAI_MODEL" help:"chat model used by /explain"`
+}
+
+type FirebaseSettings struct {
+	CredentialsFile string `yaml:"credentials_file" env:"GOOGLE_APPLICATION_CREDENTIALS" help:"service account JSON"`
+}
+
+type QuestionSettings struct {
+	MappingFile string `yaml:"mapping_file" env:"QUESTION_MAPPING_FILE" help:"mapping.json to cross-check the registry against"`
+}
+
+type CoinSettings struct {
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"COINS_REFRESH_INTERVAL" help:"how often the coin registry is reloaded"`
+}
+
+type AggregationSettings struct {
+	Scheduler         bool          `yaml:"scheduler" env:"AGGREGATION_SCHEDULER" help:"run the background scheduler"`
+	DefaultWindow     time.Duration `yaml:"default_window" env:"AGGREGATE_DEFAULT_WINDOW" help:"range POST /aggregate covers without start_time"`
+	SourceWeightsFile string        `yaml:"source_weights_file" env:"SOURCE_WEIGHTS_FILE" help:"JSON file of per-source weights"`
+	Strategies        string        `yaml:"strategies" env:"AGGREGATION_STRATEGIES" help:"comma-separated strategies the scheduler computes, including weighted_mean; empty for weighted_mean alone"`
+}
+
+type FilterSettings struct {
+	MaxPerAuthor     int           `yaml:"max_per_author" env:"FILTER_MAX_PER_AUTHOR"`
+	BurstLimit       int           `yaml:"burst_limit" env:"FILTER_BURST_LIMIT"`
+	BurstWindow      time.Duration `yaml:"burst_window" env:"FILTER_BURST_WINDOW"`
+	OutlierMethod    string        `yaml:"outlier_method" env:"FILTER_OUTLIER_METHOD" help:"mad or zscore"`
+	OutlierThreshold float64       `yaml:"outlier_threshold" env:"FILTER_OUTLIER_THRESHOLD"`
+	Window           time.Duration `yaml:"window" env:"FILTER_WINDOW" help:"trailing span of the author cap and outlier rejection; 0 for one bucket"`
+}
+
+type DecaySettings struct {
+	HalfLife time.Duration `yaml:"half_life" env:"DECAY_HALF_LIFE" help:"0 turns time-decay off"`
+}
+
+type AuthorSettings struct {
+	RepeatFactor float64       `yaml:"repeat_factor" env:"AUTHOR_REPEAT_FACTOR"`
+	RepeatWindow time.Duration `yaml:"repeat_window" env:"AUTHOR_REPEAT_WINDOW" help:"trailing span repeats are counted over; 0 for one bucket"`
+	Reputation   bool          `yaml:"reputation" env:"AUTHOR_REPUTATION"`
+}
+
+type WeightSettings struct {
+	Source        string        `yaml:"source" env:"WEIGHTS_SOURCE" help:"db, or empty for file/built-in"`
+	File          string        `yaml:"file" env:"WEIGHTS_FILE" help:"JSON weight profiles"`
+	WatchInterval time.Duration `yaml:"watch_interval" env:"WEIGHTS_WATCH_INTERVAL" help:"how often the weights file is checked"`
+}
+
+type EventSettings struct {
+	Detection    bool    `yaml:"detection" env:"EVENTS_DETECTION" help:"detect sentiment anomalies on scheduled buckets"`
+	Window       int     `yaml:"window" env:"EVENTS_WINDOW" help:"buckets in the rolling baseline"`
+	ZThreshold   float64 `yaml:"z_threshold" env:"EVENTS_Z_THRESHOLD" help:"z-score that counts as a spike"`
+	MinCount     int     `yaml:"min_count" env:"EVENTS_MIN_COUNT" help:"messages a bucket needs to be checked"`
+	ChangeWindow int     `yaml:"change_window" env:"EVENTS_CHANGE_WINDOW" help:"buckets tested for a level shift; 0 turns change-point detection off"`
+}
+
+// Defaults returns the settings used when nothing overrides them.
+func Defaults() Settings {
+	var s Settings
+	s.Server.Port = 8080
+	s.Server.StreamInterval = time.Minute
+	s.Database.MaxOpenConns = 10
+	s.Database.ConnMaxIdleTime = 5 * time.Minute
+	s.OpenAI.Model = "gpt-4.1-nano"
+	s.Questions.MappingFile = "mapping.json"
+	s.Coins.RefreshInterval = time.Minute
+	s.Aggregation.Scheduler = true
+	s.Aggregation.DefaultWindow = time.Hour
+	s.Filters.Window = time.Hour
+	s.Authors.RepeatWindow = time.Hour
+	s.Weights.WatchInterval = 30 * time.Second
+	s.Events.Detection = true
+	s.Events.Window = 24
+	s.Events.ZThreshold = 3
+	s.Events.MinCount = 5
+	s.Events.ChangeWindow = 3
+	return s
+}
+
+// Current holds the settings the service was started with.
+var Current = Defaults()
+
+// Options are the command-line switches that are not settings.
+type Options struct {
+	// PrintConfig asks for the resolved settings to be printed, secrets
+	// redacted, instead of starting the service.
+	PrintConfig bool
+}
+
+// LoadSettings resolves the settings from the config file (--config or
+// CONFIG_FILE, JSON or YAML), the environment and args, and validates them.
+func LoadSettings(args []string) (Settings, Options, error) {
+	s, opts, err := ResolveSettings(args)
+	if err != nil {
+		return s, opts, err
+	}
+	return s, opts, s.Validate()
+}
+
+// ResolveSettings is LoadSettings without the validation, for tools that
+// only need part of the service's settings.
+func ResolveSettings(args []string) (Settings, Options, error) {
+	s := Defaults()
+	var opts Options
+	fields := settingFields(&s)
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "JSON or YAML config file")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the resolved config (secrets redacted) and exit")
+	flagValues := map[string]string{}
+	for _, f := range fields {
+		key := f.key
+		fs.Func(key, f.help, func(v string) error {
+			flagValues[key] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return s, opts, err
+	}
+
+	// 1) config file
+	if *configFile != "" {
+		if err := readSettingsFile(*configFile, &s); err != nil {
+			return s, opts, err
+		}
+	}
+
+	// 2) environment; empty variables count as unset
+	env := map[string]string{}
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if v := os.Getenv(f.env); v != "" {
+			env[f.key] = v
+		}
+	}
+	if err := applySettingsFrom(fields, env, func(f settingField) string { return f.env }); err != nil {
+		return s, opts, err
+	}
+
+	// 3) flags
+	if err := applySettingsFrom(fields, flagValues, func(f settingField) string { return "--" + f.key }); err != nil {
+		return s, opts, err
+	}
+	return s, opts, nil
+}
+
+// Validate reports every invalid setting at once.
+func (s Settings) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	check(s.Server.Port > 0 && s.Server.Port < 65536, "server.port (PORT) must be between 1 and 65535")
+	check(s.Server.StreamInterval > 0, "server.stream_interval (STREAM_REFRESH_INTERVAL) must be positive")
+	check(s.Database.URL != "", "database.url (DATABASE_URL) is required")
+	check(s.Database.MaxOpenConns > 0, "database.max_open_conns (DB_MAX_OPEN_CONNS) must be positive")
+	check(s.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time (DB_CONN_MAX_IDLE_TIME) must not be negative")
+	check(s.OpenAI.APIKey != "", "openai.api_key (OPENAI_API_KEY) is required")
+	check(s.OpenAI.Model != "", "openai.model (OPENAI_MODEL) must not be empty")
+	check(s.Coins.RefreshInterval > 0, "coins.refresh_interval (COINS_REFRESH_INTERVAL) must be positive")
+	check(s.Aggregation.DefaultWindow > 0, "aggregation.default_window (AGGREGATE_DEFAULT_WINDOW) must be positive")
+	check(s.Weights.Source == "" || s.Weights.Source == "db" || s.Weights.Source == "file",
+		"weights.source (WEIGHTS_SOURCE) must be db, file or empty")
+	check(s.Weights.Source != "file" || s.Weights.File != "", "weights.file (WEIGHTS_FILE) is required when weights.source is file")
+	check(s.Weights.WatchInterval > 0, "weights.watch_interval (WEIGHTS_WATCH_INTERVAL) must be positive")
+	check(s.Events.Window >= 3, "events.window (EVENTS_WINDOW) must be at least 3")
+	check(s.Events.ZThreshold > 0, "events.z_threshold (EVENTS_Z_THRESHOLD) must be positive")
+	check(s.Events.MinCount >= 0, "events.min_count (EVENTS_MIN_COUNT) must not be negative")
+	check(s.Events.ChangeWindow >= 0 && s.Events.ChangeWindow < s.Events.Window,
+		"events.change_window (EVENTS_CHANGE_WINDOW) must be between 0 and events.window - 1")
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Redacted returns s as sorted "key = value" lines with secrets masked.
+func (s Settings) Redacted() string {
+	fields := settingFields(&s)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+	var b strings.Builder
+	for _, f := range fields {
+		v := f.format()
+		if f.secret && v != "" {
+			v = "<redacted>"
+		}
+		fmt.Fprintf(&b, "%s = %s\n", f.key, v)
+	}
+	return b.String()
+}
+
+// settingField is one leaf of Settings, addressed by its dotted key.
+type settingField struct {
+	key, env, help string
+	secret         bool
+	value          reflect.Value
+}
+
+func settingFields(s *Settings) []settingField {
+	var out []settingField
+	sv := reflect.ValueOf(s).Elem()
+	for i := 0; i < sv.NumField(); i++ {
+		section := sv.Type().Field(i)
+		group := sv.Field(i)
+		for j := 0; j < group.NumField(); j++ {
+			f := group.Type().Field(j)
+			out = append(out, settingField{
+				key:    section.Tag.Get("yaml") + "." + f.Tag.Get("yaml"),
+				env:    f.Tag.Get("env"),
+				help:   f.Tag.Get("help"),
+				secret: f.Tag.Get("secret") == "true",
+				value:  group.Field(j),
+			})
+		}
+	}
+	return out
+}
+
+// applySettingsFrom sets every field present in values (by key); name
+// labels a field in errors.
+func applySettingsFrom(fields []settingField, values map[string]string, name func(settingField) string) error {
+	for _, f := range fields {
+		v, ok := values[f.key]
+		if !ok {
+			continue
+		}
+		if err := f.set(v); err != nil {
+			return fmt.Errorf("%s: %w", name(f), err)
+		}
+	}
+	return nil
+}
+
+func (f settingField) set(v string) error {
+	v = strings.TrimSpace(v)
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(v)
+	case int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		f.value.SetInt(int64(n))
+	case float64:
+		x, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		f.value.SetFloat(x)
+	case bool:
+		switch strings.ToLower(v) {
+		case "1", "true", "on", "yes":
+			f.value.SetBool(true)
+		case "0", "false", "off", "no":
+			f.value.SetBool(false)
+		default:
+			return fmt.Errorf("%q is not on/off", v)
+		}
+	case time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s", v)
+		}
+		f.value.SetInt(int64(d))
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.value.Type())
+	}
+	return nil
+}
+
+func (f settingField) format() string {
+	return fmt.Sprint(f.value.Interface())
+}
+
+// readSettingsFile decodes a JSON or YAML config file over s. JSON is
+// read as YAML, of which it is a subset; keys that name no setting are
+// rejected.
+func readSettingsFile(path string, s *Settings) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(s); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file named name into a temp dir and
+// returns its path.
+func writeConfig(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clearEnv unsets every settings variable for the test, so the
+// environment the tests run in cannot leak in.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	s := Defaults()
+	for _, f := range settingFields(&s) {
+		if f.env != "" {
+			t.Setenv(f.env, "")
+		}
+	}
+}
+
+func TestResolveSettingsPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, "settings.yaml", `
+server:
+  port: 9000            # overridden by env and flag
+  stream_interval: 2m   # file only
+events:
+  window: 40            # overridden by env
+openai:
+  model: "file # model\u0021"  # quoted: neither a comment nor raw
+`)
+	t.Setenv("PORT", "9100")
+	t.Setenv("EVENTS_WINDOW", "30")
+
+	s, opts, err := ResolveSettings([]string{"--config", path, "--server.port=9200", "--print-config"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.PrintConfig {
+		t.Error("--print-config not set")
+	}
+	for _, tc := range []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"flag over env and file", s.Server.Port, 9200},
+		{"env over file", s.Events.Window, 30},
+		{"file over default", s.Server.StreamInterval, 2 * time.Minute},
+		{"quoted file value", s.OpenAI.Model, "file # model!"},
+		{"default", s.Coins.RefreshInterval, Defaults().Coins.RefreshInterval},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestResolveSettingsJSONFile(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, "settings.json", `{
+  "aggregation": {"strategies": "weighted_mean,trimmed_mean", "scheduler": false},
+  "events": {"z_threshold": 2.5},
+  "database": {"url": null}
+}`)
+	t.Setenv("CONFIG_FILE", path)
+	s, _, err := ResolveSettings(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Aggregation.Strategies != "weighted_mean,trimmed_mean" || s.Aggregation.Scheduler || s.Events.ZThreshold != 2.5 {
+		t.Fatalf("aggregation = %+v, z_threshold = %v", s.Aggregation, s.Events.ZThreshold)
+	}
+}
+
+func TestResolveSettingsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		file string // YAML; empty for none
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{name: "unknown key", file: "server:\n  prot: 80\n", want: "line 2: field prot not found"},
+		{name: "bad file value", file: "aggregation:\n  scheduler: maybe\n", want: "line 2: cannot unmarshal !!str `maybe` into bool"},
+		{name: "bad file duration", file: "decay:\n  half_life: 90\n", want: "line 2: cannot unmarshal !!int `90` into time.Duration"},
+		{name: "list for a string", file: "aggregation:\n  strategies: [weighted_mean]\n", want: "line 2: cannot unmarshal !!seq"},
+		{name: "bad syntax", file: "server:\n  port: [80\n", want: "settings.yml: yaml:"},
+		{name: "bad env value", env: map[string]string{"PORT": "eighty"}, want: `PORT: "eighty" is not an integer`},
+		{name: "bad flag value", args: []string{"--decay.half_life=soon"}, want: "--decay.half_life"},
+		{name: "unknown flag", args: []string{"--server.prot=80"}, want: "server.prot"},
+		{name: "missing file", args: []string{"--config", "/nonexistent/settings.yaml"}, want: "read config"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			args := tc.args
+			if tc.file != "" {
+				args = append([]string{"--config", writeConfig(t, "settings.yml", tc.file)}, args...)
+			}
+			_, _, err := ResolveSettings(args)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := Defaults()
+	err := s.Validate()
+	if err == nil {
+		t.Fatal("defaults without secrets validated")
+	}
+	for _, want := range []string{"database.url (DATABASE_URL)", "openai.api_key (OPENAI_API_KEY)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	s.Database.URL = "postgres://localhost/db"
+	s.OpenAI.APIKey = "sk-test"
+	if err := s.Validate(); err != nil {
+		t.Fatalf("valid settings rejected: %v", err)
+	}
+	s.Events.ChangeWindow = s.Events.Window
+	if err := s.Validate(); err == nil || !strings.Contains(err.Error(), "events.change_window") {
+		t.Fatalf("err = %v, want a change_window problem", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	s := Defaults()
+	s.Database.URL = "postgres://user:hunter2@db/pulse"
+	s.OpenAI.APIKey = "sk-secret"
+	out := s.Redacted()
+
+	for _, leak := range []string{"hunter2", "sk-secret"} {
+		if strings.Contains(out, leak) {
+			t.Errorf("Redacted leaks %q:\n%s", leak, out)
+		}
+	}
+	for _, want := range []string{
+		"database.url = <redacted>\n",
+		"openai.api_key = <redacted>\n",
+		"server.admin_token = \n", // an empty secret is shown as empty
+		"server.port = 8080\n",
+		"decay.half_life = 0s\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Redacted lacks %q:\n%s", want, out)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i-1] > lines[i] {
+			t.Fatalf("Redacted not sorted at %q, %q", lines[i-1], lines[i])
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// candleChunkSize bounds the rows written by one INSERT.
+const candleChunkSize = 500
+
+// Candle is one OHLCV row of the price_candles table.
+type Candle struct {
+	OpenTime time.Time `json:"open_time"`
+	Open     float64   `json:"open"`
+	High     float64   `json:"high"`
+	Low      float64   `json:"low"`
+	Close    float64   `json:"close"`
+	Volume   float64   `json:"volume"`
+}
+
+// UpsertCandles stores candles for coinID, overwriting any candle that
+// already exists for the same open time.
+func UpsertCandles(coinID int, candles []Candle) error {
+	for i := 0; i < len(candles); i += candleChunkSize {
+		end := i + candleChunkSize
+		if end > len(candles) {
+			end = len(candles)
+		}
+		if err := upsertCandleChunk(coinID, candles[i:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func upsertCandleChunk(coinID int, candles []Candle) error {
+	if len(candles) == 0 {
+		return nil
+	}
+	const cols = 7
+	values := make([]string, 0, len(candles))
+	args := make([]interface{}, 0, len(candles)*cols)
+	for i, c := range candles {
+		n := i * cols
+		values = append(values, fmt.Sprintf("($%d,$%d,$%d,$%d,$%d,$%d,$%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7))
+		args = append(args, coinID, c.OpenTime.UTC(), c.Open, c.High, c.Low, c.Close, c.Volume)
+	}
+	q := `
+      INSERT INTO price_candles (coin_id, open_time, open, high, low, close, volume)
+      VALUES ` + strings.Join(values, ",") + `
+      ON CONFLICT (coin_id, open_time) DO UPDATE
+         SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low,
+             close = EXCLUDED.close, volume = EXCLUDED.volume
+    `
+	_, err := Conn.ExecContext(context.Background(), q, args...)
+	return err
+}
+
+// FetchCandlesBetween returns coinID's candles whose open time is in
+// [start, end), oldest first.
+func FetchCandlesBetween(coinID int, start, end time.Time) ([]Candle, error) {
+	const q = `
+      SELECT open_time, open, high, low, close, volume
+        FROM price_candles
+       WHERE coin_id = $1
+         AND open_time >= $2
+         AND open_time < $3
+       ORDER BY open_time ASC
+    `
+	rows, err := Conn.QueryContext(context.Background(), q, coinID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Candle
+	for rows.Next() {
+		var c Candle
+		if err := rows.Scan(&c.OpenTime, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
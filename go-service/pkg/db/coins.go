@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Currency is one row of the currency table.
+type Currency struct {
+	ID        int    `json:"id"`
+	Code      string `json:"code"`
+	Subreddit string `json:"subreddit"`
+	// Enabled coins are aggregated and streamed; disabled ones keep their
+	// history but are skipped.
+	Enabled bool `json:"enabled"`
+}
+
+// ErrCurrencyInUse is returned when a currency cannot be deleted because
+// messages or aggregates still refer to it.
+var ErrCurrencyInUse = errors.New("currency is still referenced")
+
+// ErrCurrencyCodeTaken is returned when another currency has the code.
+var ErrCurrencyCodeTaken = errors.New("currency code already in use")
+
+// ListCurrencies returns every currency ordered by ID.
+func ListCurrencies() ([]Currency, error) {
+	const q = `
+      SELECT id, code, COALESCE(subreddit, ''), enabled
+        FROM currency
+       ORDER BY id
+    `
+	rows, err := Conn.QueryContext(context.Background(), q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Currency
+	for rows.Next() {
+		var c Currency
+		if err := rows.Scan(&c.ID, &c.Code, &c.Subreddit, &c.Enabled); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// InsertCurrency stores c and returns it with its ID. A zero c.ID lets
+// the table pick one; an explicit one moves the id sequence past it, so
+// later picks do not collide. It returns ErrCurrencyCodeTaken when
+// another currency has c.Code.
+func InsertCurrency(c Currency) (Currency, error) {
+	ctx := context.Background()
+	tx, err := Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return c, err
+	}
+	defer tx.Rollback()
+
+	if c.ID == 0 {
+		err = tx.QueryRowContext(ctx, `
+          INSERT INTO currency (code, subreddit, enabled)
+          VALUES ($1, $2, $3)
+          RETURNING id`, c.Code, nullString(c.Subreddit), c.Enabled).Scan(&c.ID)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+          INSERT INTO currency (id, code, subreddit, enabled)
+          VALUES ($1, $2, $3, $4)`, c.ID, c.Code, nullString(c.Subreddit), c.Enabled)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `
+              SELECT setval(pg_get_serial_sequence('currency', 'id'), MAX(id))
+                FROM currency`)
+		}
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == currencyCodeIndex { // unique_violation
+		return c, ErrCurrencyCodeTaken
+	}
+	if err != nil {
+		return c, err
+	}
+	return c, tx.Commit()
+}
+
+// UpdateCurrency overwrites the row with c.ID and reports whether it
+// existed.
+func UpdateCurrency(c Currency) (bool, error) {
+	res, err := Conn.ExecContext(context.Background(), `
+      UPDATE currency
+         SET code = $2, subreddit = $3, enabled = $4
+       WHERE id = $1`, c.ID, c.Code, nullString(c.Subreddit), c.Enabled)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteCurrency removes the currency with id and reports whether it
+// existed. It returns ErrCurrencyInUse while other rows refer to it.
+func DeleteCurrency(id int) (bool, error) {
+	res, err := Conn.ExecContext(context.Background(),
+		`DELETE FROM currency WHERE id = $1`, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+			return false, ErrCurrencyInUse
+		}
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// nullString stores empty strings as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -3,13 +3,16 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"log"
-	"os"
 	"time"
 	"fmt"
 	"strings"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
 )
 
 type RawMessage struct {
@@ -19,19 +22,20 @@ type RawMessage struct {
 
 var Conn *sql.DB
 
+// dsn is the connection string Conn was opened with, kept for the
+// dedicated LISTEN connection.
+var dsn string
+
 // InitDB initializes the global Conn handle.
-func InitDB() {
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		log.Fatal("DATABASE_URL not set")
-	}
+func InitDB(s config.DatabaseSettings) {
 	var err error
-	Conn, err = sql.Open("pgx", dsn)
+	dsn = s.URL
+	Conn, err = sql.Open("pgx", s.URL)
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
-	Conn.SetMaxOpenConns(10)
-	Conn.SetConnMaxIdleTime(5 * time.Minute)
+	Conn.SetMaxOpenConns(s.MaxOpenConns)
+	Conn.SetConnMaxIdleTime(s.ConnMaxIdleTime)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -47,6 +51,8 @@ type MessageScore struct {
     CurrencyID     int
     SentimentScore float64
     CreatedAt      time.Time
+    Author         string
+    Source         string
 }
 
 // FetchMessageScoresFromDB pulls question_id, currency_id, sentiment_score, created_at
@@ -89,28 +95,49 @@ func FetchMessageScoresFromDB() ([]MessageScore, error) {
     return out, nil
 }
 
+// Series identifies one stored aggregate series: a strategy at a resolution.
+type Series struct {
+    Strategy   string
+    Resolution string
+}
+
+// LastScore is the most recent stored score of a coin and the window it
+// belongs to, so callers can tell how old it is.
+type LastScore struct {
+    WindowStart time.Time
+    Score       float64
+}
+
 // FetchInitialLastSentiments returns the most recent sentiment_score
-// for each coin in coinIDs, before the given time.
+// for each coin in coinIDs, before the given time, within one series.
 // It uses a single DISTINCT ON query.
-func FetchInitialLastSentiments(coinIDs []int, before time.Time) (map[int]float64, error) {
+func FetchInitialLastSentiments(coinIDs []int, series Series, before time.Time) (map[int]LastScore, error) {
+    if len(coinIDs) == 0 {
+        return map[int]LastScore{}, nil // IN () is not valid SQL
+    }
     // build a SQL placeholder list: ($1,$2, …)
     placeholders := make([]string, len(coinIDs))
-    args := make([]interface{}, len(coinIDs)+1)
+    args := make([]interface{}, len(coinIDs)+3)
     for i, id := range coinIDs {
         placeholders[i] = fmt.Sprintf("$%d", i+1)
         args[i] = id
     }
-    // the last arg is the `before` timestamp
-    args[len(coinIDs)] = before
+    // the last args are the `before` timestamp and the series
+    n := len(coinIDs)
+    args[n] = before
+    args[n+1] = series.Strategy
+    args[n+2] = series.Resolution
 
     sql := fmt.Sprintf(`
         SELECT DISTINCT ON (coin_id)
-               coin_id, sentiment_score
+               coin_id, window_start, sentiment_score
           FROM aggregated_sentiments
          WHERE coin_id IN (%s)
            AND window_start < $%d
+           AND strategy = $%d
+           AND resolution = $%d
          ORDER BY coin_id, window_start DESC
-    `, strings.Join(placeholders, ","), len(coinIDs)+1)
+    `, strings.Join(placeholders, ","), n+1, n+2, n+3)
 
     rows, err := Conn.QueryContext(context.Background(), sql, args...)
     if err != nil {
@@ -118,45 +145,287 @@ func FetchInitialLastSentiments(coinIDs []int, before time.Time) (map[int]float6
     }
     defer rows.Close()
 
-    result := make(map[int]float64, len(coinIDs))
+    result := make(map[int]LastScore, len(coinIDs))
     for rows.Next() {
         var cid int
-        var score float64
-        if err := rows.Scan(&cid, &score); err != nil {
+        var last LastScore
+        if err := rows.Scan(&cid, &last.WindowStart, &last.Score); err != nil {
             return nil, err
         }
-        result[cid] = score
+        last.WindowStart = last.WindowStart.UTC()
+        result[cid] = last
     }
     return result, rows.Err()
 }
 
-// InsertAggregatedSentimentBatch bulk-inserts all new records at once.
-func InsertAggregatedSentimentBatch(records []struct {
-    CoinID     int
-    Window     time.Time
-    Sentiment  float64
-}) error {
+// insertBatchSize caps the rows per INSERT so the statement stays under
+// Postgres' bind-parameter limit.
+const insertBatchSize = 1000
+
+// InsertAggregatedSentimentBatch bulk-inserts all new records, in chunks
+// of insertBatchSize, and returns the ones that were inserted.
+// Windows that already exist are left untouched.
+func InsertAggregatedSentimentBatch(records []AggregatedSentiment) ([]AggregatedSentiment, error) {
+    var inserted []AggregatedSentiment
+    for len(records) > 0 {
+        n := len(records)
+        if n > insertBatchSize {
+            n = insertBatchSize
+        }
+        written, err := insertAggregatedSentimentChunk(Conn, records[:n], insertSkipExisting)
+        if err != nil {
+            return inserted, err
+        }
+        inserted = append(inserted, written...)
+        records = records[n:]
+    }
+    return inserted, nil
+}
+
+// ReplaceAggregatedSentimentBatch writes records over any existing rows
+// for the same windows. Each replaced row is first copied to
+// aggregated_sentiments_history, then overwritten with its revision
+// bumped. Everything commits or rolls back together.
+func ReplaceAggregatedSentimentBatch(records []AggregatedSentiment) error {
     if len(records) == 0 {
         return nil
     }
-    // build a VALUES list: ($1,$2,$3),($4,$5,$6),…
+    ctx := context.Background()
+    tx, err := Conn.BeginTx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    for len(records) > 0 {
+        n := len(records)
+        if n > insertBatchSize {
+            n = insertBatchSize
+        }
+        if err := archiveAggregatedSentimentChunk(tx, records[:n]); err != nil {
+            return fmt.Errorf("archive: %w", err)
+        }
+        if _, err := insertAggregatedSentimentChunk(tx, records[:n], insertReplaceExisting); err != nil {
+            return fmt.Errorf("replace: %w", err)
+        }
+        records = records[n:]
+    }
+    return tx.Commit()
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+    ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+    QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// conflict clauses for insertAggregatedSentimentChunk
+const (
+    insertSkipExisting = `ON CONFLICT (coin_id, resolution, strategy, window_start) DO NOTHING`
+
+    insertReplaceExisting = `ON CONFLICT (coin_id, resolution, strategy, window_start) DO UPDATE SET
+          sentiment_score = EXCLUDED.sentiment_score,
+          message_count   = EXCLUDED.message_count,
+          score_mean      = EXCLUDED.score_mean,
+          score_stddev    = EXCLUDED.score_stddev,
+          score_min       = EXCLUDED.score_min,
+          score_max       = EXCLUDED.score_max,
+          question_counts = EXCLUDED.question_counts,
+          question_scores = EXCLUDED.question_scores,
+          dropped_counts  = EXCLUDED.dropped_counts,
+          source_scores   = EXCLUDED.source_scores,
+          source_counts   = EXCLUDED.source_counts,
+          weight_profile  = EXCLUDED.weight_profile,
+          revision        = aggregated_sentiments.revision + 1,
+          computed_at     = now()`
+)
+
+// archiveAggregatedSentimentChunk copies the stored rows for the windows
+// of records into aggregated_sentiments_history.
+func archiveAggregatedSentimentChunk(ex execer, records []AggregatedSentiment) error {
+    const cols = 4
+    placeholders := make([]string, len(records))
+    args := make([]interface{}, 0, len(records)*cols)
+    for i, rec := range records {
+        placeholders[i] = fmt.Sprintf("($%d::int,$%d::text,$%d::text,$%d::timestamptz)",
+            i*cols+1, i*cols+2, i*cols+3, i*cols+4)
+        args = append(args, rec.CurrencyID, rec.Resolution, rec.Strategy, rec.WindowStart)
+    }
+    sql := fmt.Sprintf(`
+        INSERT INTO aggregated_sentiments_history
+          (coin_id, window_start, strategy, resolution, revision,
+           sentiment_score, message_count, computed_at, snapshot)
+        SELECT coin_id, window_start, strategy, resolution, revision,
+               sentiment_score, message_count, computed_at, to_jsonb(a) - 'id'
+          FROM aggregated_sentiments a
+         WHERE (coin_id, resolution, strategy, window_start) IN (VALUES %s)
+    `, strings.Join(placeholders, ","))
+
+    _, err := ex.ExecContext(context.Background(), sql, args...)
+    return err
+}
+
+// insertAggregatedSentimentChunk writes records with the given conflict
+// clause and returns the ones Postgres reports as written.
+func insertAggregatedSentimentChunk(ex execer, records []AggregatedSentiment, onConflict string) ([]AggregatedSentiment, error) {
+    const cols = 16
+    // build a VALUES list: ($1,…,$16),($17,…,$32),…
     var placeholders []string
-    args := make([]interface{}, 0, len(records)*3)
+    args := make([]interface{}, 0, len(records)*cols)
     for i, rec := range records {
-        base := i*3 + 1
-        placeholders = append(placeholders, fmt.Sprintf("($%d,$%d,$%d)",
-            base, base+1, base+2))
-        args = append(args, rec.CoinID, rec.Window, rec.Sentiment)
+        ph := make([]string, cols)
+        for j := range ph {
+            ph[j] = fmt.Sprintf("$%d", i*cols+j+1)
+        }
+        placeholders = append(placeholders, "("+strings.Join(ph, ",")+")")
+
+        qc, err := jsonObject(rec.Stats.QuestionCounts)
+        if err != nil {
+            return nil, err
+        }
+        topics, err := jsonObject(rec.Topics)
+        if err != nil {
+            return nil, err
+        }
+        dropped, err := jsonObject(rec.Stats.Dropped)
+        if err != nil {
+            return nil, err
+        }
+        sources, err := jsonObject(rec.Sources)
+        if err != nil {
+            return nil, err
+        }
+        sourceCounts, err := jsonObject(rec.Stats.SourceCounts)
+        if err != nil {
+            return nil, err
+        }
+        args = append(args, rec.CurrencyID, rec.WindowStart, rec.SentimentScore,
+            rec.Strategy, rec.Resolution,
+            rec.Stats.Count, rec.Stats.Mean, rec.Stats.StdDev,
+            rec.Stats.Min, rec.Stats.Max, qc, topics, dropped,
+            sources, sourceCounts, rec.Profile)
     }
     sql := fmt.Sprintf(`
         INSERT INTO aggregated_sentiments
-          (coin_id, window_start, sentiment_score)
+          (coin_id, window_start, sentiment_score, strategy, resolution,
+           message_count, score_mean, score_stddev, score_min, score_max,
+           question_counts, question_scores, dropped_counts,
+           source_scores, source_counts, weight_profile)
         VALUES %s
-        ON CONFLICT (coin_id, window_start) DO NOTHING
-    `, strings.Join(placeholders, ","))
+        %s
+        RETURNING coin_id, resolution, strategy, window_start
+    `, strings.Join(placeholders, ","), onConflict)
 
-    _, err := Conn.ExecContext(context.Background(), sql, args...)
-    return err
+    rows, err := ex.QueryContext(context.Background(), sql, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    type rowKey struct {
+        coin                 int
+        resolution, strategy string
+        window               int64
+    }
+    byKey := make(map[rowKey]AggregatedSentiment, len(records))
+    for _, rec := range records {
+        byKey[rowKey{rec.CurrencyID, rec.Resolution, rec.Strategy, rec.WindowStart.UnixNano()}] = rec
+    }
+    var written []AggregatedSentiment
+    for rows.Next() {
+        var k rowKey
+        var window time.Time
+        if err := rows.Scan(&k.coin, &k.resolution, &k.strategy, &window); err != nil {
+            return written, err
+        }
+        k.window = window.UnixNano()
+        if rec, ok := byKey[k]; ok {
+            written = append(written, rec)
+        }
+    }
+    return written, rows.Err()
+}
+
+// jsonObject encodes a map for a JSONB column, writing {} for nil maps.
+func jsonObject(v interface{}) (string, error) {
+    b, err := json.Marshal(v)
+    if err != nil {
+        return "", err
+    }
+    if string(b) == "null" {
+        return "{}", nil
+    }
+    return string(b), nil
+}
+
+// FetchNextFreshWindows returns, for each coin in coinIDs that has one,
+// the first window of series starting in [from, before) that was computed
+// from messages rather than carried forward.
+func FetchNextFreshWindows(coinIDs []int, series Series, from, before time.Time) (map[int]time.Time, error) {
+    if len(coinIDs) == 0 {
+        return map[int]time.Time{}, nil // IN () is not valid SQL
+    }
+    placeholders := make([]string, len(coinIDs))
+    args := make([]interface{}, len(coinIDs)+4)
+    for i, id := range coinIDs {
+        placeholders[i] = fmt.Sprintf("$%d", i+1)
+        args[i] = id
+    }
+    n := len(coinIDs)
+    args[n] = from
+    args[n+1] = before
+    args[n+2] = series.Strategy
+    args[n+3] = series.Resolution
+
+    sql := fmt.Sprintf(`
+        SELECT coin_id, MIN(window_start)
+          FROM aggregated_sentiments
+         WHERE coin_id IN (%s)
+           AND window_start >= $%d
+           AND window_start < $%d
+           AND strategy = $%d
+           AND resolution = $%d
+           AND message_count > 0
+         GROUP BY coin_id
+    `, strings.Join(placeholders, ","), n+1, n+2, n+3, n+4)
+
+    rows, err := Conn.QueryContext(context.Background(), sql, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    result := make(map[int]time.Time, len(coinIDs))
+    for rows.Next() {
+        var cid int
+        var t time.Time
+        if err := rows.Scan(&cid, &t); err != nil {
+            return nil, err
+        }
+        result[cid] = t.UTC()
+    }
+    return result, rows.Err()
+}
+
+// FetchLatestAggregatedWindow returns the newest window_start stored in
+// series before the given time, or the zero time if there is none.
+func FetchLatestAggregatedWindow(series Series, before time.Time) (time.Time, error) {
+    const q = `
+      SELECT MAX(window_start)
+        FROM aggregated_sentiments
+       WHERE window_start < $1
+         AND strategy = $2
+         AND resolution = $3
+    `
+    var latest sql.NullTime
+    row := Conn.QueryRowContext(context.Background(), q, before, series.Strategy, series.Resolution)
+    if err := row.Scan(&latest); err != nil {
+        return time.Time{}, err
+    }
+    if !latest.Valid {
+        return time.Time{}, nil
+    }
+    return latest.Time.UTC(), nil
 }
 
 // FetchRawMessagesBetween returns every raw_messages row whose created_at
@@ -167,7 +436,9 @@ func FetchRawMessagesBetween(start, end time.Time) ([]MessageScore, error) {
           question_id,
           currency_id,
           sentiment_score,
-          created_at
+          created_at,
+          COALESCE(author, ''),
+          source
         FROM raw_messages
        WHERE created_at >= $1
          AND created_at <  $2
@@ -187,6 +458,8 @@ func FetchRawMessagesBetween(start, end time.Time) ([]MessageScore, error) {
             &m.CurrencyID,
             &m.SentimentScore,
             &m.CreatedAt,
+            &m.Author,
+            &m.Source,
         ); err != nil {
             return nil, err
         }
@@ -196,15 +469,15 @@ func FetchRawMessagesBetween(start, end time.Time) ([]MessageScore, error) {
 }
 
 // FetchLastAggregatedSentiment returns the most recent sentiment_score
-// for a single coin before the given time (or 0 if none).
-func FetchLastAggregatedSentiment(coinID int, before time.Time) (float64, error) {
+// for a single coin in series before the given time (or 0 if none).
+func FetchLastAggregatedSentiment(coinID int, series Series, before time.Time) (float64, error) {
     // reuse the bulk helper for a single-element slice
-    m, err := FetchInitialLastSentiments([]int{coinID}, before)
+    m, err := FetchInitialLastSentiments([]int{coinID}, series, before)
     if err != nil {
         return 0, err
     }
     if v, ok := m[coinID]; ok {
-        return v, nil
+        return v.Score, nil
     }
     return 0, nil
 }
@@ -214,19 +487,39 @@ type AggregatedSentiment struct {
     CurrencyID     int
     WindowStart    time.Time
     SentimentScore float64
+    Strategy       string
+    Resolution     string
+    Stats          model.BucketStats
+    // Topics holds the average score per question ID.
+    Topics         map[string]float64
+    // Sources holds the sub-score per message source.
+    Sources        map[string]float64
+    // Profile names the weight profile that produced the score.
+    Profile        string
+    // Revision counts how often the window was written; it starts at 1
+    // and grows with every recompute that replaced it.
+    Revision       int
+    ComputedAt     time.Time
 }
 
 // FetchAggregatedSentimentsBetween returns all aggregated_sentiments
-// rows whose window_start is in [start, end], oldest first.
-func FetchAggregatedSentimentsBetween(start, end time.Time) ([]AggregatedSentiment, error) {
+// rows in series whose window_start is in [start, end], oldest first.
+func FetchAggregatedSentimentsBetween(start, end time.Time, series Series) ([]AggregatedSentiment, error) {
     const q = `
-      SELECT coin_id, window_start, sentiment_score
+      SELECT coin_id, window_start, sentiment_score, strategy, resolution,
+             message_count, score_mean, score_stddev, score_min, score_max,
+             question_counts, question_scores, dropped_counts,
+             source_scores, source_counts, weight_profile,
+             revision, computed_at
         FROM aggregated_sentiments
        WHERE window_start >= $1
          AND window_start <= $2
+         AND strategy = $3
+         AND resolution = $4
        ORDER BY window_start ASC
     `
-    rows, err := Conn.QueryContext(context.Background(), q, start, end)
+    rows, err := Conn.QueryContext(context.Background(), q, start, end,
+        series.Strategy, series.Resolution)
     if err != nil {
         return nil, err
     }
@@ -234,8 +527,32 @@ func FetchAggregatedSentimentsBetween(start, end time.Time) ([]AggregatedSentime
 
     var out []AggregatedSentiment
     for rows.Next() {
-        var a AggregatedSentiment
-        if err := rows.Scan(&a.CurrencyID, &a.WindowStart, &a.SentimentScore); err != nil {
+        var (
+            a                   AggregatedSentiment
+            qc, topics, dropped []byte
+            sources, srcCounts  []byte
+        )
+        if err := rows.Scan(&a.CurrencyID, &a.WindowStart, &a.SentimentScore,
+            &a.Strategy, &a.Resolution,
+            &a.Stats.Count, &a.Stats.Mean, &a.Stats.StdDev,
+            &a.Stats.Min, &a.Stats.Max, &qc, &topics, &dropped,
+            &sources, &srcCounts, &a.Profile,
+            &a.Revision, &a.ComputedAt); err != nil {
+            return nil, err
+        }
+        if err := json.Unmarshal(sources, &a.Sources); err != nil {
+            return nil, err
+        }
+        if err := json.Unmarshal(srcCounts, &a.Stats.SourceCounts); err != nil {
+            return nil, err
+        }
+        if err := json.Unmarshal(dropped, &a.Stats.Dropped); err != nil {
+            return nil, err
+        }
+        if err := json.Unmarshal(qc, &a.Stats.QuestionCounts); err != nil {
+            return nil, err
+        }
+        if err := json.Unmarshal(topics, &a.Topics); err != nil {
             return nil, err
         }
         out = append(out, a)
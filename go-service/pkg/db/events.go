@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SentimentEvent is one row of the sentiment_events table: a bucket whose
+// score stood out from the coin's recent history.
+type SentimentEvent struct {
+	ID          int64     `json:"id"`
+	CurrencyID  int       `json:"coin_id"`
+	WindowStart time.Time `json:"window_start"`
+	Strategy    string    `json:"strategy"`
+	Resolution  string    `json:"resolution"`
+	// Method names the detector: "zscore" or "changepoint".
+	Method string `json:"method"`
+	// Direction is "up" or "down".
+	Direction string `json:"direction"`
+	// Severity is "minor", "major" or "critical".
+	Severity string `json:"severity"`
+	// Statistic is the detector's test value, e.g. the z-score.
+	Statistic  float64   `json:"statistic"`
+	Sentiment  float64   `json:"sentiment"`
+	Baseline   float64   `json:"baseline"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// EventFilter narrows FetchSentimentEvents. Zero fields match everything.
+type EventFilter struct {
+	CoinIDs    []int
+	Resolution string
+	Severities []string
+	Start, End time.Time
+	Limit      int
+}
+
+// InsertSentimentEvents stores events and returns the ones that were new,
+// with their ID and detection time set. Events already stored for the
+// same coin, series, method and window are skipped.
+func InsertSentimentEvents(events []SentimentEvent) ([]SentimentEvent, error) {
+	var out []SentimentEvent
+	for _, e := range events {
+		err := Conn.QueryRowContext(context.Background(), `
+          INSERT INTO sentiment_events
+            (coin_id, window_start, strategy, resolution, method,
+             direction, severity, statistic, sentiment, baseline)
+          VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+          ON CONFLICT (coin_id, strategy, resolution, method, window_start) DO NOTHING
+          RETURNING id, detected_at`,
+			e.CurrencyID, e.WindowStart.UTC(), e.Strategy, e.Resolution, e.Method,
+			e.Direction, e.Severity, e.Statistic, e.Sentiment, e.Baseline,
+		).Scan(&e.ID, &e.DetectedAt)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// DeleteSentimentEvents removes the events of every method detected on
+// the windows of rows, so they can be detected again after the rows were
+// replaced. It returns how many events were removed.
+func DeleteSentimentEvents(rows []AggregatedSentiment) (int64, error) {
+	var removed int64
+	for len(rows) > 0 {
+		n := len(rows)
+		if n > insertBatchSize {
+			n = insertBatchSize
+		}
+		const cols = 4
+		placeholders := make([]string, n)
+		args := make([]interface{}, 0, n*cols)
+		for i, r := range rows[:n] {
+			placeholders[i] = fmt.Sprintf("($%d::int,$%d::text,$%d::text,$%d::timestamptz)",
+				i*cols+1, i*cols+2, i*cols+3, i*cols+4)
+			args = append(args, r.CurrencyID, r.Strategy, r.Resolution, r.WindowStart.UTC())
+		}
+		res, err := Conn.ExecContext(context.Background(), fmt.Sprintf(`
+          DELETE FROM sentiment_events
+           WHERE (coin_id, strategy, resolution, window_start) IN (VALUES %s)`,
+			strings.Join(placeholders, ",")), args...)
+		if err != nil {
+			return removed, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return removed, err
+		}
+		removed += affected
+		rows = rows[n:]
+	}
+	return removed, nil
+}
+
+// FetchSentimentEvents returns the events matching f, newest window first.
+func FetchSentimentEvents(f EventFilter) ([]SentimentEvent, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if len(f.CoinIDs) > 0 {
+		ph := make([]string, len(f.CoinIDs))
+		for i, id := range f.CoinIDs {
+			ph[i] = arg(id)
+		}
+		where = append(where, "coin_id IN ("+strings.Join(ph, ",")+")")
+	}
+	if f.Resolution != "" {
+		where = append(where, "resolution = "+arg(f.Resolution))
+	}
+	if len(f.Severities) > 0 {
+		ph := make([]string, len(f.Severities))
+		for i, s := range f.Severities {
+			ph[i] = arg(s)
+		}
+		where = append(where, "severity IN ("+strings.Join(ph, ",")+")")
+	}
+	if !f.Start.IsZero() {
+		where = append(where, "window_start >= "+arg(f.Start))
+	}
+	if !f.End.IsZero() {
+		where = append(where, "window_start < "+arg(f.End))
+	}
+	q := `
+      SELECT id, coin_id, window_start, strategy, resolution, method,
+             direction, severity, statistic, sentiment, baseline, detected_at
+        FROM sentiment_events`
+	if len(where) > 0 {
+		q += "\n       WHERE " + strings.Join(where, "\n         AND ")
+	}
+	q += "\n       ORDER BY window_start DESC, id DESC"
+	if f.Limit > 0 {
+		q += "\n       LIMIT " + arg(f.Limit)
+	}
+
+	rows, err := Conn.QueryContext(context.Background(), q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []SentimentEvent{}
+	for rows.Next() {
+		var e SentimentEvent
+		if err := rows.Scan(&e.ID, &e.CurrencyID, &e.WindowStart, &e.Strategy,
+			&e.Resolution, &e.Method, &e.Direction, &e.Severity,
+			&e.Statistic, &e.Sentiment, &e.Baseline, &e.DetectedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AggregatesChannel is the NOTIFY channel the aggregated_sentiments
+// triggers publish on.
+const AggregatesChannel = "aggregated_sentiments"
+
+// Reconnect backoff bounds for ListenAggregates.
+const (
+	listenMinBackoff = time.Second
+	listenMaxBackoff = 30 * time.Second
+)
+
+// AggregateNotice is the payload of one AggregatesChannel notification:
+// a statement wrote Rows rows of one series, the newest at Latest.
+type AggregateNotice struct {
+	Strategy   string    `json:"strategy"`
+	Resolution string    `json:"resolution"`
+	Latest     time.Time `json:"latest"`
+	Rows       int       `json:"rows"`
+}
+
+// ListenAggregates LISTENs on AggregatesChannel over a dedicated
+// connection, outside the Conn pool, and calls fn for every notice until
+// ctx is done. A lost connection is re-established with exponential
+// backoff; notices sent while it was down are missed, so callers should
+// keep polling as a fallback.
+func ListenAggregates(ctx context.Context, fn func(AggregateNotice)) {
+	go func() {
+		backoff := listenMinBackoff
+		for {
+			started := time.Now()
+			err := listenOnce(ctx, fn)
+			if ctx.Err() != nil {
+				log.Println("[Listen] stopped")
+				return
+			}
+			if time.Since(started) > listenMaxBackoff {
+				backoff = listenMinBackoff // it had been up a while
+			}
+			log.Printf("[Listen] connection lost: %v; reconnecting in %s", err, backoff)
+			select {
+			case <-ctx.Done():
+				log.Println("[Listen] stopped")
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > listenMaxBackoff {
+				backoff = listenMaxBackoff
+			}
+		}
+	}()
+}
+
+// listenOnce connects, LISTENs and dispatches notices until the
+// connection fails or ctx is done.
+func listenOnce(ctx context.Context, fn func(AggregateNotice)) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+AggregatesChannel); err != nil {
+		return err
+	}
+	log.Printf("[Listen] listening on %s", AggregatesChannel)
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var notice AggregateNotice
+		if err := json.Unmarshal([]byte(n.Payload), &notice); err != nil {
+			log.Printf("[Listen] bad payload %q: %v", n.Payload, err)
+			continue
+		}
+		fn(notice)
+	}
+}
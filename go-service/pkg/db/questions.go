@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+)
+
+// FetchQuestions returns every row of the questions table.
+func FetchQuestions() ([]config.Question, error) {
+	rows, err := Conn.QueryContext(context.Background(),
+		`SELECT id, code, text FROM questions ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []config.Question
+	for rows.Next() {
+		var (
+			id int
+			q  config.Question
+		)
+		if err := rows.Scan(&id, &q.Code, &q.Text); err != nil {
+			return nil, err
+		}
+		q.ID = strconv.Itoa(id)
+		out = append(out, q)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// AuthorReputation scales the influence of one author's messages.
+type AuthorReputation struct {
+	Author    string    `json:"author"`
+	Weight    float64   `json:"weight"`
+	Note      string    `json:"note"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListAuthorReputations returns every reputation entry ordered by author.
+func ListAuthorReputations() ([]AuthorReputation, error) {
+	const q = `
+      SELECT author, weight, note, updated_at
+        FROM author_reputation
+       ORDER BY author
+    `
+	rows, err := Conn.QueryContext(context.Background(), q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AuthorReputation
+	for rows.Next() {
+		var a AuthorReputation
+		if err := rows.Scan(&a.Author, &a.Weight, &a.Note, &a.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// FetchAuthorWeights returns the reputation weight per author.
+func FetchAuthorWeights() (map[string]float64, error) {
+	list, err := ListAuthorReputations()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]float64, len(list))
+	for _, a := range list {
+		out[a.Author] = a.Weight
+	}
+	return out, nil
+}
+
+// UpsertAuthorReputation creates or replaces the entry for a.Author and
+// returns it as stored.
+func UpsertAuthorReputation(a AuthorReputation) (AuthorReputation, error) {
+	const q = `
+      INSERT INTO author_reputation (author, weight, note, updated_at)
+      VALUES ($1, $2, $3, now())
+      ON CONFLICT (author) DO UPDATE
+         SET weight = EXCLUDED.weight,
+             note = EXCLUDED.note,
+             updated_at = EXCLUDED.updated_at
+      RETURNING updated_at
+    `
+	err := Conn.QueryRowContext(context.Background(), q, a.Author, a.Weight, a.Note).
+		Scan(&a.UpdatedAt)
+	return a, err
+}
+
+// DeleteAuthorReputation removes the entry for author and reports whether
+// one existed.
+func DeleteAuthorReputation(author string) (bool, error) {
+	res, err := Conn.ExecContext(context.Background(),
+		`DELETE FROM author_reputation WHERE author = $1`, author)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// currencyCodeIndex keeps currency codes unique.
+const currencyCodeIndex = "currency_code_key"
+
+// schema lists the idempotent statements the service needs on top of the
+// tables created by the seeder. They run in order on every start.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS aggregated_sentiments (
+	  id              SERIAL PRIMARY KEY,
+	  coin_id         INTEGER NOT NULL REFERENCES currency(id),
+	  window_start    TIMESTAMPTZ NOT NULL,
+	  sentiment_score FLOAT NOT NULL,
+	  UNIQUE (coin_id, window_start)
+	)`,
+
+	// one row per strategy so methods can be compared side by side
+	`ALTER TABLE aggregated_sentiments
+	   ADD COLUMN IF NOT EXISTS strategy TEXT NOT NULL DEFAULT 'weighted_mean'`,
+	`ALTER TABLE aggregated_sentiments
+	   DROP CONSTRAINT IF EXISTS aggregated_sentiments_coin_id_window_start_key`,
+
+	// bucket width; rows written before resolutions existed are 5m
+	`ALTER TABLE aggregated_sentiments
+	   ADD COLUMN IF NOT EXISTS resolution TEXT NOT NULL DEFAULT '5m'`,
+	`DROP INDEX IF EXISTS aggregated_sentiments_coin_strategy_window_key`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS aggregated_sentiments_series_window_key
+	   ON aggregated_sentiments (coin_id, resolution, strategy, window_start)`,
+
+	// volume and dispersion of the messages behind each score
+	`ALTER TABLE aggregated_sentiments
+	   ADD COLUMN IF NOT EXISTS message_count   INTEGER NOT NULL DEFAULT 0,
+	   ADD COLUMN IF NOT EXISTS score_mean      FLOAT   NOT NULL DEFAULT 0,
+	   ADD COLUMN IF NOT EXISTS score_stddev    FLOAT   NOT NULL DEFAULT 0,
+	   ADD COLUMN IF NOT EXISTS score_min       FLOAT   NOT NULL DEFAULT 0,
+	   ADD COLUMN IF NOT EXISTS score_max       FLOAT   NOT NULL DEFAULT 0,
+	   ADD COLUMN IF NOT EXISTS question_counts JSONB   NOT NULL DEFAULT '{}'::jsonb`,
+
+	// per-question (topic) averages, keyed by question ID
+	`ALTER TABLE aggregated_sentiments
+	   ADD COLUMN IF NOT EXISTS question_scores JSONB NOT NULL DEFAULT '{}'::jsonb`,
+
+	// how many messages each filter rule dropped, keyed by rule name
+	`ALTER TABLE aggregated_sentiments
+	   ADD COLUMN IF NOT EXISTS dropped_counts JSONB NOT NULL DEFAULT '{}'::jsonb`,
+
+	// per-source sub-scores and message counts next to the blended score
+	`ALTER TABLE aggregated_sentiments
+	   ADD COLUMN IF NOT EXISTS source_scores JSONB NOT NULL DEFAULT '{}'::jsonb,
+	   ADD COLUMN IF NOT EXISTS source_counts JSONB NOT NULL DEFAULT '{}'::jsonb`,
+
+	// optional per-author influence used by author-aware aggregation
+	`CREATE TABLE IF NOT EXISTS author_reputation (
+	  author     TEXT PRIMARY KEY,
+	  weight     FLOAT NOT NULL CHECK (weight >= 0),
+	  note       TEXT NOT NULL DEFAULT '',
+	  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+
+	// recompute: rows carry a revision, replaced rows move to history
+	`ALTER TABLE aggregated_sentiments
+	   ADD COLUMN IF NOT EXISTS revision    INTEGER     NOT NULL DEFAULT 1,
+	   ADD COLUMN IF NOT EXISTS computed_at TIMESTAMPTZ NOT NULL DEFAULT now()`,
+	`CREATE TABLE IF NOT EXISTS aggregated_sentiments_history (
+	  id              SERIAL PRIMARY KEY,
+	  coin_id         INTEGER NOT NULL,
+	  window_start    TIMESTAMPTZ NOT NULL,
+	  strategy        TEXT NOT NULL,
+	  resolution      TEXT NOT NULL,
+	  revision        INTEGER NOT NULL,
+	  sentiment_score FLOAT NOT NULL,
+	  message_count   INTEGER NOT NULL,
+	  computed_at     TIMESTAMPTZ NOT NULL,
+	  superseded_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	  snapshot        JSONB NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS aggregated_sentiments_history_series_idx
+	   ON aggregated_sentiments_history (coin_id, resolution, strategy, window_start)`,
+
+	// per-question weights, read when WEIGHTS_SOURCE=db
+	`CREATE TABLE IF NOT EXISTS question_weights (
+	  question_id TEXT PRIMARY KEY,
+	  weight      FLOAT NOT NULL CHECK (weight >= 0)
+	)`,
+
+	// named weight profiles: existing weights belong to the default one
+	`ALTER TABLE question_weights
+	   ADD COLUMN IF NOT EXISTS profile TEXT NOT NULL DEFAULT 'default'`,
+	`ALTER TABLE question_weights DROP CONSTRAINT IF EXISTS question_weights_pkey`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS question_weights_profile_question_key
+	   ON question_weights (profile, question_id)`,
+	`CREATE TABLE IF NOT EXISTS coin_weight_profiles (
+	  coin_code TEXT PRIMARY KEY,
+	  profile   TEXT NOT NULL
+	)`,
+	`ALTER TABLE aggregated_sentiments
+	   ADD COLUMN IF NOT EXISTS weight_profile TEXT NOT NULL DEFAULT 'default'`,
+
+	// coins can be switched off without losing their history
+	`ALTER TABLE currency
+	   ADD COLUMN IF NOT EXISTS enabled BOOLEAN NOT NULL DEFAULT true`,
+	// weight profiles and source weights refer to coins by code
+	`CREATE UNIQUE INDEX IF NOT EXISTS ` + currencyCodeIndex + ` ON currency (code)`,
+
+	// OHLCV candles imported per coin, for sentiment/price correlation
+	`CREATE TABLE IF NOT EXISTS price_candles (
+	  coin_id   INTEGER NOT NULL REFERENCES currency(id),
+	  open_time TIMESTAMPTZ NOT NULL,
+	  open      FLOAT NOT NULL,
+	  high      FLOAT NOT NULL,
+	  low       FLOAT NOT NULL,
+	  close     FLOAT NOT NULL,
+	  volume    FLOAT NOT NULL DEFAULT 0,
+	  PRIMARY KEY (coin_id, open_time)
+	)`,
+
+	// sentiment spikes and level shifts found in newly aggregated buckets
+	`CREATE TABLE IF NOT EXISTS sentiment_events (
+	  id           BIGSERIAL PRIMARY KEY,
+	  coin_id      INTEGER NOT NULL REFERENCES currency(id),
+	  window_start TIMESTAMPTZ NOT NULL,
+	  strategy     TEXT NOT NULL,
+	  resolution   TEXT NOT NULL,
+	  method       TEXT NOT NULL,
+	  direction    TEXT NOT NULL,
+	  severity     TEXT NOT NULL,
+	  statistic    FLOAT NOT NULL,
+	  sentiment    FLOAT NOT NULL,
+	  baseline     FLOAT NOT NULL,
+	  detected_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	  UNIQUE (coin_id, strategy, resolution, method, window_start)
+	)`,
+	`CREATE INDEX IF NOT EXISTS sentiment_events_window_idx
+	   ON sentiment_events (window_start DESC)`,
+
+	// every write to aggregated_sentiments, whatever path makes it,
+	// notifies AggregatesChannel once per series it touched
+	`CREATE OR REPLACE FUNCTION notify_aggregated_sentiments() RETURNS trigger AS $$
+	DECLARE
+	  r record;
+	BEGIN
+	  FOR r IN
+	    SELECT strategy, resolution, max(window_start) AS latest, count(*) AS rows
+	      FROM new_rows
+	     GROUP BY strategy, resolution
+	  LOOP
+	    PERFORM pg_notify('aggregated_sentiments', json_build_object(
+	      'strategy', r.strategy, 'resolution', r.resolution,
+	      'latest', r.latest, 'rows', r.rows)::text);
+	  END LOOP;
+	  RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS aggregated_sentiments_notify_insert ON aggregated_sentiments`,
+	`CREATE TRIGGER aggregated_sentiments_notify_insert
+	   AFTER INSERT ON aggregated_sentiments
+	   REFERENCING NEW TABLE AS new_rows
+	   FOR EACH STATEMENT EXECUTE FUNCTION notify_aggregated_sentiments()`,
+	`DROP TRIGGER IF EXISTS aggregated_sentiments_notify_update ON aggregated_sentiments`,
+	`CREATE TRIGGER aggregated_sentiments_notify_update
+	   AFTER UPDATE ON aggregated_sentiments
+	   REFERENCING NEW TABLE AS new_rows
+	   FOR EACH STATEMENT EXECUTE FUNCTION notify_aggregated_sentiments()`,
+}
+
+// EnsureSchema applies schema against Conn.
+func EnsureSchema() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, stmt := range schema {
+		if _, err := Conn.ExecContext(ctx, stmt); err != nil {
+			log.Fatalf("Failed to apply schema: %v\n%s", err, stmt)
+		}
+	}
+	log.Println("✅ Database schema up to date")
+}
@@ -0,0 +1,51 @@
+package db
+
+import "context"
+
+// FetchWeightProfiles returns the question_weights table as profile name
+// → question ID → weight, together with coin_weight_profiles as coin code
+// → profile name.
+func FetchWeightProfiles() (map[string]map[string]float64, map[string]string, error) {
+	ctx := context.Background()
+	rows, err := Conn.QueryContext(ctx,
+		`SELECT profile, question_id, weight FROM question_weights`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	profiles := map[string]map[string]float64{}
+	for rows.Next() {
+		var (
+			profile, id string
+			w           float64
+		)
+		if err := rows.Scan(&profile, &id, &w); err != nil {
+			return nil, nil, err
+		}
+		if profiles[profile] == nil {
+			profiles[profile] = map[string]float64{}
+		}
+		profiles[profile][id] = w
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	crows, err := Conn.QueryContext(ctx,
+		`SELECT coin_code, profile FROM coin_weight_profiles`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer crows.Close()
+
+	coins := map[string]string{}
+	for crows.Next() {
+		var code, profile string
+		if err := crows.Scan(&code, &profile); err != nil {
+			return nil, nil, err
+		}
+		coins[code] = profile
+	}
+	return profiles, coins, crows.Err()
+}
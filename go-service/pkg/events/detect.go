@@ -0,0 +1,230 @@
+// Package events detects sentiment spikes and level shifts in newly
+// aggregated buckets, stores them and fans them out to subscribers.
+package events
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// Detector methods, as stored in sentiment_events.method.
+const (
+	MethodZScore      = "zscore"
+	MethodChangePoint = "changepoint"
+)
+
+// flatStd is the spread below which a baseline counts as flat, so the
+// rounding noise of a constant series is not read as a tiny deviation
+// that any move would exceed.
+const flatStd = 1e-9
+
+// Severities, from least to most severe.
+var Severities = []string{"minor", "major", "critical"}
+
+// Config tunes the detectors.
+type Config struct {
+	// Enabled turns detection on for scheduled buckets.
+	Enabled bool
+	// Window is how many earlier buckets form the rolling baseline.
+	Window int
+	// ZThreshold is the test value at which a bucket becomes an event.
+	// 1.5× and 2× the threshold make it major and critical.
+	ZThreshold float64
+	// MinCount is the messages a bucket needs to be checked at all, so
+	// thin buckets do not raise noise.
+	MinCount int
+	// ChangeWindow is how many of the latest buckets are compared with
+	// the baseline before them to find a level shift; 0 turns the
+	// change-point detector off.
+	ChangeWindow int
+}
+
+// DefaultConfig is the configuration the scheduler detects with.
+var DefaultConfig = Config{Enabled: true, Window: 24, ZThreshold: 3, MinCount: 5, ChangeWindow: 3}
+
+// Configure installs s as DefaultConfig.
+func Configure(s config.EventSettings) {
+	DefaultConfig = Config{
+		Enabled:      s.Detection,
+		Window:       s.Window,
+		ZThreshold:   s.ZThreshold,
+		MinCount:     s.MinCount,
+		ChangeWindow: s.ChangeWindow,
+	}
+}
+
+// Detect checks cur against history, the same coin's earlier rows in the
+// same series ordered oldest first, and returns the events it raises.
+// Carried-forward rows and rows below cfg.MinCount are ignored on both
+// sides.
+func Detect(cfg Config, history []db.AggregatedSentiment, cur db.AggregatedSentiment) []db.SentimentEvent {
+	if cur.Stats.Count == 0 || cur.Stats.Count < cfg.MinCount {
+		return nil
+	}
+	var scores []float64
+	for _, a := range history {
+		if a.Stats.Count > 0 && a.Stats.Count >= cfg.MinCount && a.WindowStart.Before(cur.WindowStart) {
+			scores = append(scores, a.SentimentScore)
+		}
+	}
+	event := func(method string, stat, baseline float64) db.SentimentEvent {
+		dir := "up"
+		if stat < 0 {
+			dir = "down"
+		}
+		return db.SentimentEvent{
+			CurrencyID:  cur.CurrencyID,
+			WindowStart: cur.WindowStart.UTC(),
+			Strategy:    cur.Strategy,
+			Resolution:  cur.Resolution,
+			Method:      method,
+			Direction:   dir,
+			Severity:    severity(stat, cfg.ZThreshold),
+			Statistic:   stat,
+			Sentiment:   cur.SentimentScore,
+			Baseline:    baseline,
+		}
+	}
+
+	var out []db.SentimentEvent
+	// 1) Rolling z-score of this bucket against the window before it
+	if base := tail(scores, cfg.Window); len(base) >= minBaseline(cfg.Window) {
+		mean, std := meanStd(base)
+		if std > flatStd {
+			if z := (cur.SentimentScore - mean) / std; math.Abs(z) >= cfg.ZThreshold {
+				out = append(out, event(MethodZScore, z, mean))
+			}
+		}
+	}
+
+	// 2) Change point: the latest buckets' mean against the window before
+	//    them. Only the bucket that first crosses the threshold is an
+	//    event, not every bucket of a lasting shift.
+	if k := cfg.ChangeWindow; k > 0 {
+		all := append(append([]float64(nil), scores...), cur.SentimentScore)
+		stat, baseline, ok := shift(all, cfg.Window, k)
+		if ok && math.Abs(stat) >= cfg.ZThreshold {
+			prev, _, prevOK := shift(scores, cfg.Window, k)
+			if !prevOK || math.Abs(prev) < cfg.ZThreshold || (prev > 0) != (stat > 0) {
+				out = append(out, event(MethodChangePoint, stat, baseline))
+			}
+		}
+	}
+	return out
+}
+
+// shift tests whether the mean of the last k scores differs from the
+// window scores before them. It returns the shift in standard errors of
+// the reference window and the reference mean.
+func shift(scores []float64, window, k int) (stat, baseline float64, ok bool) {
+	if len(scores) < k {
+		return 0, 0, false
+	}
+	recent := scores[len(scores)-k:]
+	ref := tail(scores[:len(scores)-k], window)
+	if len(ref) < minBaseline(window) {
+		return 0, 0, false
+	}
+	mean, std := meanStd(ref)
+	if std <= flatStd {
+		return 0, 0, false
+	}
+	rm, _ := meanStd(recent)
+	return (rm - mean) / (std / math.Sqrt(float64(k))), mean, true
+}
+
+// minBaseline is the fewest baseline buckets a test needs: half the
+// window, and never fewer than three.
+func minBaseline(window int) int {
+	if n := window / 2; n > 3 {
+		return n
+	}
+	return 3
+}
+
+func severity(stat, threshold float64) string {
+	switch a := math.Abs(stat); {
+	case a >= 2*threshold:
+		return Severities[2]
+	case a >= 1.5*threshold:
+		return Severities[1]
+	default:
+		return Severities[0]
+	}
+}
+
+func tail(xs []float64, n int) []float64 {
+	if len(xs) > n {
+		return xs[len(xs)-n:]
+	}
+	return xs
+}
+
+func meanStd(xs []float64) (mean, std float64) {
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	for _, x := range xs {
+		std += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(xs)))
+}
+
+// Process runs the detectors over records, freshly stored rows of one
+// series at res, stores the events they raise and publishes the new ones.
+func Process(cfg Config, res model.Resolution, records []db.AggregatedSentiment) ([]db.SentimentEvent, error) {
+	if !cfg.Enabled || len(records) == 0 {
+		return nil, nil
+	}
+	series := db.Series{Strategy: records[0].Strategy, Resolution: records[0].Resolution}
+	first, last := records[0].WindowStart, records[0].WindowStart
+	for _, r := range records {
+		if r.WindowStart.Before(first) {
+			first = r.WindowStart
+		}
+		if r.WindowStart.After(last) {
+			last = r.WindowStart
+		}
+	}
+
+	// 1) Load each coin's history, far enough back for a full baseline
+	lookback := time.Duration(cfg.Window+cfg.ChangeWindow) * res.Step
+	stored, err := db.FetchAggregatedSentimentsBetween(first.Add(-lookback), last, series)
+	if err != nil {
+		return nil, fmt.Errorf("fetch history: %w", err)
+	}
+	history := map[int][]db.AggregatedSentiment{}
+	for _, a := range stored {
+		history[a.CurrencyID] = append(history[a.CurrencyID], a)
+	}
+
+	// 2) Check every record against the rows before it
+	sorted := append([]db.AggregatedSentiment(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].WindowStart.Before(sorted[j].WindowStart) })
+	var found []db.SentimentEvent
+	for _, r := range sorted {
+		h := history[r.CurrencyID]
+		i := sort.Search(len(h), func(i int) bool { return !h[i].WindowStart.Before(r.WindowStart) })
+		found = append(found, Detect(cfg, h[:i], r)...)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	// 3) Store and publish the ones not seen before
+	fresh, err := db.InsertSentimentEvents(found)
+	if err != nil {
+		return fresh, fmt.Errorf("insert events: %w", err)
+	}
+	for _, e := range fresh {
+		publish(e)
+	}
+	return fresh, nil
+}
@@ -0,0 +1,163 @@
+package events
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+var (
+	testStart  = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	testConfig = Config{Enabled: true, Window: 10, ZThreshold: 3, MinCount: 5, ChangeWindow: 3}
+)
+
+// series turns scores into consecutive 5m rows of one coin, each with
+// count messages.
+func series(count int, scores ...float64) []db.AggregatedSentiment {
+	out := make([]db.AggregatedSentiment, len(scores))
+	for i, s := range scores {
+		out[i] = db.AggregatedSentiment{
+			CurrencyID:     1,
+			WindowStart:    testStart.Add(time.Duration(i) * 5 * time.Minute),
+			SentimentScore: s,
+			Strategy:       model.DefaultStrategy,
+			Resolution:     "5m",
+			Stats:          model.BucketStats{Count: count},
+		}
+	}
+	return out
+}
+
+// baseline is n scores alternating around 0 with a standard deviation of
+// 0.1.
+func baseline(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = 0.1
+		if i%2 == 1 {
+			out[i] = -0.1
+		}
+	}
+	return out
+}
+
+// methods lists the method and direction of each event.
+func methods(evs []db.SentimentEvent) []string {
+	var out []string
+	for _, e := range evs {
+		out = append(out, e.Method+"/"+e.Direction)
+	}
+	return out
+}
+
+func TestDetect(t *testing.T) {
+	flip := testConfig
+	flip.ChangeWindow = 1
+
+	for _, tc := range []struct {
+		name   string
+		cfg    Config
+		scores []float64
+		count  int
+		want   []string
+	}{
+		{"spike", testConfig, append(baseline(10), 0.5), 10, []string{"zscore/up"}},
+		{"dip", testConfig, append(baseline(10), -0.5), 10, []string{"zscore/down"}},
+		{"within noise", testConfig, append(baseline(10), 0.2), 10, nil},
+		{"direction flip", flip, append(baseline(10), 0.6, -0.6), 10, []string{"zscore/down", "changepoint/down"}},
+		{"thin buckets", testConfig, append(baseline(10), 0.5), 4, nil},
+		{"flat baseline", testConfig, []float64{0.2, 0.2, 0.2, 0.2, 0.2, 0.2, 0.2, 0.2, 0.2, 0.2, 0.9}, 10, nil},
+		{"short history", testConfig, []float64{0.1, -0.1, 0.1, -0.1, 0.9}, 10, nil},
+	} {
+		rows := series(tc.count, tc.scores...)
+		got := methods(Detect(tc.cfg, rows[:len(rows)-1], rows[len(rows)-1]))
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: events = %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: events = %v, want %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDetectSkipsThinHistory(t *testing.T) {
+	history := series(2, baseline(10)...) // below MinCount: no baseline
+	cur := series(10, append(baseline(10), 0.9)...)[10]
+	if evs := Detect(testConfig, history, cur); len(evs) != 0 {
+		t.Fatalf("events = %v, want none", methods(evs))
+	}
+}
+
+func TestDetectLastingShiftFiresOnce(t *testing.T) {
+	cfg := testConfig
+	cfg.ZThreshold = 2
+	rows := series(10, append(baseline(10), 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5)...)
+
+	var fired []time.Time
+	for i := 10; i < len(rows); i++ {
+		for _, e := range Detect(cfg, rows[:i], rows[i]) {
+			if e.Method == MethodChangePoint {
+				if e.Direction != "up" {
+					t.Errorf("bucket %d: direction = %s, want up", i, e.Direction)
+				}
+				fired = append(fired, e.WindowStart)
+			}
+		}
+	}
+	if len(fired) != 1 {
+		t.Fatalf("change point fired at %v, want exactly once", fired)
+	}
+}
+
+func TestShift(t *testing.T) {
+	stat, base, ok := shift(append(baseline(10), 0.3, 0.3, 0.3), 10, 3)
+	if !ok {
+		t.Fatal("shift not computed")
+	}
+	// the recent mean is 0.3 over a reference of mean 0 and std 0.1
+	if want := 0.3 / (0.1 / math.Sqrt(3)); math.Abs(stat-want) > 1e-9 || math.Abs(base) > 1e-9 {
+		t.Fatalf("shift = %v (baseline %v), want %v (baseline 0)", stat, base, want)
+	}
+	if _, _, ok := shift([]float64{0.1, 0.2}, 10, 3); ok {
+		t.Error("shift computed with fewer scores than k")
+	}
+	if _, _, ok := shift(append(baseline(4), 0.3, 0.3, 0.3), 10, 3); ok {
+		t.Error("shift computed on a reference shorter than minBaseline")
+	}
+	if _, _, ok := shift([]float64{0.2, 0.2, 0.2, 0.2, 0.2, 0.5, 0.5, 0.5}, 10, 3); ok {
+		t.Error("shift computed on a flat reference")
+	}
+}
+
+func TestMinBaseline(t *testing.T) {
+	for window, want := range map[int]int{0: 3, 3: 3, 6: 3, 8: 4, 10: 5, 24: 12} {
+		if got := minBaseline(window); got != want {
+			t.Errorf("minBaseline(%d) = %d, want %d", window, got, want)
+		}
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	for _, tc := range []struct {
+		stat float64
+		want string
+	}{
+		{3, "minor"},
+		{-4.4, "minor"},
+		{4.5, "major"},
+		{-5.9, "major"},
+		{6, "critical"},
+		{-9, "critical"},
+	} {
+		if got := severity(tc.stat, 3); got != tc.want {
+			t.Errorf("severity(%v, 3) = %s, want %s", tc.stat, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"log"
+	"sync"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further events to it are dropped.
+const subscriberBuffer = 32
+
+var (
+	subsMu sync.Mutex
+	subs   = map[chan db.SentimentEvent]struct{}{}
+)
+
+// Subscribe returns a channel that receives every newly detected event
+// and a function that unsubscribes and closes it.
+func Subscribe() (<-chan db.SentimentEvent, func()) {
+	ch := make(chan db.SentimentEvent, subscriberBuffer)
+	subsMu.Lock()
+	subs[ch] = struct{}{}
+	subsMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			subsMu.Lock()
+			delete(subs, ch)
+			subsMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publish hands e to every subscriber without waiting on any of them.
+func publish(e db.SentimentEvent) {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	for ch := range subs {
+		select {
+		case ch <- e:
+		default:
+			log.Printf("[Events] subscriber full, dropped event %d", e.ID)
+		}
+	}
+}
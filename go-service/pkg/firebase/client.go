@@ -3,7 +3,6 @@ package firebase
 import (
     "context"
     "log"
-    "os"
 
     firebase "firebase.google.com/go/v4"
     "cloud.google.com/go/firestore"
@@ -15,9 +14,10 @@ var (
     client *firestore.Client
 )
 
-func Init() {
+// Init connects to Firestore with the service account in credentialsFile.
+func Init(credentialsFile string) {
     ctx := context.Background()
-    sa := option.WithCredentialsFile(os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"))
+    sa := option.WithCredentialsFile(credentialsFile)
     app, err := firebase.NewApp(ctx, nil, sa)
     if err != nil {
         log.Fatalf("firebase.NewApp: %v", err)
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/aggregate"
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// requireAdmin checks the X-Admin-Token header against the configured
+// admin token and writes an error when it does not match. Admin endpoints
+// are disabled while no token is configured.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	want := config.Current.Server.AdminToken
+	if want == "" {
+		http.Error(w, "admin API disabled", http.StatusForbidden)
+		return false
+	}
+	got := r.Header.Get("X-Admin-Token")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		http.Error(w, "invalid admin token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// AuthorReputationHandler manages the author reputation table.
+//
+//	GET    /admin/authors              list every entry
+//	PUT    /admin/authors              body {"author","weight","note"}
+//	DELETE /admin/authors?author=name  remove an entry
+func AuthorReputationHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		list, err := db.ListAuthorReputations()
+		if err != nil {
+			log.Printf("[Admin] list authors error: %v", err)
+			http.Error(w, "could not list authors", http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []db.AuthorReputation{}
+		}
+		writeJSON(w, http.StatusOK, list)
+
+	case http.MethodPut, http.MethodPost:
+		var req db.AuthorReputation
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		req.Author = strings.TrimSpace(req.Author)
+		if req.Author == "" {
+			http.Error(w, "author required", http.StatusBadRequest)
+			return
+		}
+		if req.Weight < 0 {
+			http.Error(w, "weight must not be negative", http.StatusBadRequest)
+			return
+		}
+		saved, err := db.UpsertAuthorReputation(req)
+		if err != nil {
+			log.Printf("[Admin] upsert author %q error: %v", req.Author, err)
+			http.Error(w, "could not save author", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[Admin] author %q weight set to %g", saved.Author, saved.Weight)
+		writeJSON(w, http.StatusOK, saved)
+
+	case http.MethodDelete:
+		author := r.URL.Query().Get("author")
+		if author == "" {
+			http.Error(w, "author query required", http.StatusBadRequest)
+			return
+		}
+		found, err := db.DeleteAuthorReputation(author)
+		if err != nil {
+			log.Printf("[Admin] delete author %q error: %v", author, err)
+			http.Error(w, "could not delete author", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "author not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("[Admin] author %q removed", author)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeJSON encodes v as the response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[HTTP] JSON encode error: %v", err)
+	}
+}
+
+// WeightsHandler serves GET /admin/weights with the weight profiles in
+// effect, the coins assigned to them and where they were loaded from.
+func WeightsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, weightsResponse{
+		Source:         aggregate.WeightsSource(),
+		WeightProfiles: model.Profiles(),
+	})
+}
+
+// ReloadWeightsHandler handles POST /admin/weights/reload. A rejected
+// configuration answers 400 and leaves the current weights in place.
+func ReloadWeightsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	profiles, err := aggregate.ReloadWeights()
+	if err != nil {
+		log.Printf("[Admin] weight reload rejected: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, weightsResponse{
+		Source:         aggregate.WeightsSource(),
+		WeightProfiles: profiles,
+	})
+}
+
+type weightsResponse struct {
+	Source string `json:"source"`
+	model.WeightProfiles
+}
@@ -2,185 +2,201 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/cosmic-hash/CryptoPulse/pkg/aggregate"
+	"github.com/cosmic-hash/CryptoPulse/pkg/coins"
 	"github.com/cosmic-hash/CryptoPulse/pkg/config"
     "github.com/cosmic-hash/CryptoPulse/pkg/db"
 	"github.com/cosmic-hash/CryptoPulse/pkg/model"
 )
 
-type CoinInfo struct {
-    ID        int
-    Code      string
-    Subreddit string
+// coinByCode finds a coin in the registry by its ticker code.
+func coinByCode(code string) (db.Currency, bool) {
+    return coins.ByCode(code)
 }
 
-var coinsList = []CoinInfo{
-    {91,  "BTC",  "Bitcoin"},
-    {92,  "ETH",  "ethereum"},
-    {93,  "USDT", "Tether+CryptoCurrency"},
-    {97,  "XRP",  "Ripple"},
-    {95,  "BNB",  "binance"},
-    {99,  "SOL",  "solana"},
-    {94,  "USDC", "CryptoCurrency"},
-    {103,  "TRX",  "Tronix"},
-    {100,  "DOGE", "dogecoin"},
-    {96, "ADA",  "cardano"},
+// AggregationCoins returns the enabled coins in the shape the aggregation
+// engine expects.
+func AggregationCoins() []aggregate.Coin {
+    enabled := coins.Enabled()
+    out := make([]aggregate.Coin, 0, len(enabled))
+    for _, c := range enabled {
+        out = append(out, aggregate.Coin{ID: c.ID, Code: c.Code})
+    }
+    return out
 }
 
-// AggregateRequest lets caller override the window.
+// AggregateRequest lets caller override the window, strategy and resolution.
+// A request that also overrides filters, decay, sources, authors or the
+// weight profile is computed as a preview: nothing of it is stored.
 type AggregateRequest struct {
-    StartTime string `json:"start_time"` // RFC3339
-    EndTime   string `json:"end_time"`   // RFC3339
+    StartTime  string `json:"start_time"` // RFC3339
+    EndTime    string `json:"end_time"`   // RFC3339
+    Strategy   string `json:"strategy"`   // see model.Aggregators
+    Resolution string `json:"resolution"` // see model.Resolutions
+    // Filters overrides the service-wide filter settings for this run.
+    Filters *aggregate.FilterConfig `json:"filters"`
+    // Decay overrides the service-wide time-decay settings for this run.
+    Decay *aggregate.DecayConfig `json:"decay"`
+    // Sources overrides the service-wide per-source weights for this run.
+    Sources *aggregate.SourceWeights `json:"sources"`
+    // Authors overrides the service-wide author weighting for this run.
+    Authors *aggregate.AuthorConfig `json:"authors"`
+    // WeightProfile scores every coin with this profile instead of its own.
+    WeightProfile string `json:"weight_profile"`
+}
+
+// options validates the overrides in req and turns them into engine options.
+func (req AggregateRequest) options() (aggregate.Options, error) {
+    opts := aggregate.Options{
+        Strategy:   req.Strategy,
+        Resolution: req.Resolution,
+        Filters:    req.Filters,
+        Decay:      req.Decay,
+        Sources:    req.Sources,
+        Authors:    req.Authors,
+        Profile:    req.WeightProfile,
+    }
+    if _, err := model.LookupAggregator(req.Strategy); err != nil {
+        return opts, err
+    }
+    if _, err := model.LookupResolution(req.Resolution); err != nil {
+        return opts, err
+    }
+    if req.Filters != nil {
+        if err := req.Filters.Validate(); err != nil {
+            return opts, err
+        }
+    }
+    if req.Decay != nil {
+        if err := req.Decay.Validate(); err != nil {
+            return opts, err
+        }
+    }
+    if req.Sources != nil {
+        if err := req.Sources.Validate(); err != nil {
+            return opts, err
+        }
+    }
+    if req.Authors != nil {
+        if err := req.Authors.Validate(); err != nil {
+            return opts, err
+        }
+    }
+    if req.WeightProfile != "" {
+        if _, ok := model.Profiles().Profiles[req.WeightProfile]; !ok {
+            return opts, fmt.Errorf("unknown weight profile %q", req.WeightProfile)
+        }
+    }
+    return opts, nil
 }
 
-// AggregateHandler handles POST /aggregate
+// AggregateHandler handles POST /aggregate. It stores the closed windows
+// it computes, so it is an admin endpoint, and its range is bounded like
+// a recompute's.
 func AggregateHandler(w http.ResponseWriter, r *http.Request) {
-    type AggregateRequest struct {
-        StartTime string `json:"start_time"` // RFC3339
-        EndTime   string `json:"end_time"`   // RFC3339
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if !requireAdmin(w, r) {
+        return
     }
     var req AggregateRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         http.Error(w, "invalid JSON", http.StatusBadRequest)
         return
     }
+    opts, err := req.options()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
 
     // 1) Determine window
     now := time.Now().UTC()
     end := now
-    start := now.Add(-1 * time.Hour)
+    start := now.Add(-config.Current.Aggregation.DefaultWindow)
     if req.EndTime != "" {
-        if t, err := time.Parse(time.RFC3339, req.EndTime); err == nil {
-            end = t.UTC()
+        t, err := time.Parse(time.RFC3339, req.EndTime)
+        if err != nil {
+            http.Error(w, fmt.Sprintf("bad end_time: %v", err), http.StatusBadRequest)
+            return
         }
+        end = t.UTC()
     }
     if req.StartTime != "" {
-        if t, err := time.Parse(time.RFC3339, req.StartTime); err == nil {
-            start = t.UTC()
+        t, err := time.Parse(time.RFC3339, req.StartTime)
+        if err != nil {
+            http.Error(w, fmt.Sprintf("bad start_time: %v", err), http.StatusBadRequest)
+            return
         }
+        start = t.UTC()
     }
-
-    // 2) Fetch raw messages
-    raw, err := db.FetchRawMessagesBetween(start, end)
-    if err != nil {
-        log.Printf("[Aggregate] fetch raw error: %v", err)
-        http.Error(w, "db fetch failed", http.StatusInternalServerError)
+    if !start.Before(end) {
+        http.Error(w, "start_time must be before end_time", http.StatusBadRequest)
         return
     }
-
-    // 3) Build buckets
-    window := 5 * time.Minute
-    start = start.Truncate(window)
-    var buckets []time.Time
-    for t := start; !t.After(end); t = t.Add(window) {
-        buckets = append(buckets, t)
-    }
-    grouped := make(map[time.Time]map[int][]db.MessageScore, len(buckets))
-    for _, t := range buckets {
-        grouped[t] = make(map[int][]db.MessageScore)
-    }
-    for _, m := range raw {
-        b := m.CreatedAt.UTC().Truncate(window)
-        if b.Before(start) {
-            b = start
-        }
-        if b.After(end) {
-            continue
-        }
-        grouped[b][m.CurrencyID] = append(grouped[b][m.CurrencyID], m)
+    if end.Sub(start) > maxRecomputeSpan {
+        http.Error(w, fmt.Sprintf("range exceeds %s", maxRecomputeSpan), http.StatusBadRequest)
+        return
     }
 
-    // 4) Prefetch last-known sentiments
-    var coinIDs []int
-    for _, c := range coinsList {
-        coinIDs = append(coinIDs, c.ID)
-    }
-    lastSent, err := db.FetchInitialLastSentiments(coinIDs, start)
+    // 2) Compute every resolution up to the requested one and store the
+    //    closed windows (duplicates noop); open ones are only returned
+    buckets, err := aggregate.Run(AggregationCoins(), start, end, opts)
     if err != nil {
-        log.Printf("[Aggregate] fetch initial error: %v", err)
-        http.Error(w, "internal error", http.StatusInternalServerError)
+        log.Printf("[Aggregate] run error: %v", err)
+        http.Error(w, "aggregation failed", http.StatusInternalServerError)
         return
     }
-
-    // 5) Compute & collect
     type bucketEntry struct {
-        Time  string             `json:"time"`
-        Coins map[string]float64 `json:"coins"`
-    }
-    var (
-        resp     []bucketEntry
-        toInsert []struct {
-            CoinID    int
-            Window    time.Time
-            Sentiment float64
-        }
-    )
-
-    for _, t := range buckets {
-        coinsOut := make(map[string]float64, len(coinsList))
-
-        for _, coin := range coinsList {
-            msgs := grouped[t][coin.ID]
-            var sent float64
-
-            if len(msgs) > 0 {
-                // fresh compute
-                qScores := map[string][]float64{}
-                for _, m := range msgs {
-                    if name, ok := config.QuestionMapping[m.QuestionID]; ok {
-                        qScores[name] = append(qScores[name], m.SentimentScore)
-                    }
-                }
-                sent = model.CalculateFinalSentiment(model.DefaultWeights, qScores)
-                lastSent[coin.ID] = sent
-
-            } else {
-                // carry-forward or backfill
-                if prev, ok := lastSent[coin.ID]; ok {
-                    sent = prev
-                } else {
-                    hist, err := db.FetchLastAggregatedSentiment(coin.ID, t)
-                    if err != nil {
-                        log.Printf("[Aggregate] backfill error for coin %d: %v", coin.ID, err)
-                    }
-                    sent = hist
-                    lastSent[coin.ID] = sent
-                }
-            }
-
-            //  Always schedule an insert, fresh or carried
-            toInsert = append(toInsert, struct {
-                CoinID    int
-                Window    time.Time
-                Sentiment float64
-            }{coin.ID, t, sent})
-
-            coinsOut[coin.Code] = sent
-        }
-
+        Time       string                       `json:"time"`
+        Strategy   string                       `json:"strategy"`
+        Resolution string                       `json:"resolution"`
+        Coins      map[string]float64           `json:"coins"`
+        Stats      map[string]model.BucketStats `json:"stats"`
+        Sources    map[string]map[string]float64 `json:"sources"`
+        Profiles   map[string]string            `json:"weight_profiles"`
+    }
+    resp := make([]bucketEntry, 0, len(buckets))
+    for _, b := range buckets {
         resp = append(resp, bucketEntry{
-            Time:  t.Format(time.RFC3339),
-            Coins: coinsOut,
+            Time:       b.Time.Format(time.RFC3339),
+            Strategy:   b.Strategy,
+            Resolution: b.Resolution,
+            Coins:      b.Coins,
+            Stats:      b.Stats,
+            Sources:    b.Sources,
+            Profiles:   b.Profiles,
         })
     }
 
-    // 6) Bulk insert everything (duplicates noop)
-    if err := db.InsertAggregatedSentimentBatch(toInsert); err != nil {
-        log.Printf("[Aggregate] Bulk insert error: %v", err)
-    } else {
-        log.Printf("[Aggregate] Bulk insert OK: %d records", len(toInsert))
-    }
-
-    // 7) Return JSON
+    // 3) Return JSON
     w.Header().Set("Content-Type", "application/json")
     if err := json.NewEncoder(w).Encode(resp); err != nil {
         log.Printf("[Aggregate] JSON encode error: %v", err)
     }
 }
+
+// AggregateStatusHandler serves GET /aggregate/status with the
+// background scheduler's progress.
+func AggregateStatusHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    if err := json.NewEncoder(w).Encode(aggregate.CurrentStatus()); err != nil {
+        log.Printf("[Aggregate] status encode error: %v", err)
+    }
+}
+
 // HelloHandler serves GET /
 func HelloHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
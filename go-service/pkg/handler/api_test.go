@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAggregateRejectsBadRequests(t *testing.T) {
+	withAdminToken(t, "secret")
+
+	for _, tc := range []struct {
+		name, method, token, body string
+		code                      int
+		want                      string
+	}{
+		{"get", http.MethodGet, "secret", `{}`, http.StatusMethodNotAllowed, "Method not allowed"},
+		{"no token", http.MethodPost, "", `{}`, http.StatusUnauthorized, "invalid admin token"},
+		{"bad start", http.MethodPost, "secret", `{"start_time":"yesterday"}`, http.StatusBadRequest, "bad start_time"},
+		{"bad end", http.MethodPost, "secret", `{"end_time":"2026-01-01"}`, http.StatusBadRequest, "bad end_time"},
+		{"reversed", http.MethodPost, "secret",
+			`{"start_time":"2026-01-02T00:00:00Z","end_time":"2026-01-01T00:00:00Z"}`,
+			http.StatusBadRequest, "start_time must be before end_time"},
+		{"too long", http.MethodPost, "secret",
+			`{"start_time":"2026-01-01T00:00:00Z","end_time":"2026-03-01T00:00:00Z"}`,
+			http.StatusBadRequest, "range exceeds"},
+	} {
+		req := httptest.NewRequest(tc.method, "/aggregate", strings.NewReader(tc.body))
+		if tc.token != "" {
+			req.Header.Set("X-Admin-Token", tc.token)
+		}
+		rec := httptest.NewRecorder()
+		AggregateHandler(rec, req)
+		if rec.Code != tc.code || !strings.Contains(rec.Body.String(), tc.want) {
+			t.Errorf("%s: %d %q, want %d mentioning %q", tc.name, rec.Code, rec.Body.String(), tc.code, tc.want)
+		}
+	}
+
+	withAdminToken(t, "")
+	req := httptest.NewRequest(http.MethodPost, "/aggregate", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	AggregateHandler(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("without an admin token configured: %d, want 403", rec.Code)
+	}
+}
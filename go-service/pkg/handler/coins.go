@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/coins"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+// coinPatch holds the fields a PATCH may change; nil leaves a field as
+// is. Code may only repeat the coin's current code.
+type coinPatch struct {
+	Code      *string `json:"code"`
+	Subreddit *string `json:"subreddit"`
+	Enabled   *bool   `json:"enabled"`
+}
+
+// CoinsHandler serves the coin registry.
+//
+//	GET    /coins       list every coin (public)
+//	POST   /coins       body {"id"?,"code","subreddit","enabled"?} (admin)
+//	PATCH  /coins/{id}  body with subreddit and/or enabled (admin)
+//	DELETE /coins/{id}  remove a coin nothing refers to (admin)
+func CoinsHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, "/coins"), "/")
+	switch {
+	case r.Method == http.MethodGet && idStr == "":
+		writeJSON(w, http.StatusOK, coins.All())
+	case r.Method == http.MethodPost && idStr == "":
+		if requireAdmin(w, r) {
+			createCoin(w, r)
+		}
+	case (r.Method == http.MethodPatch || r.Method == http.MethodDelete) && idStr != "":
+		if !requireAdmin(w, r) {
+			return
+		}
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid coin id", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPatch {
+			patchCoin(w, r, id)
+		} else {
+			deleteCoin(w, id)
+		}
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func createCoin(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		db.Currency
+		Enabled *bool `json:"enabled"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	c := req.Currency
+	c.Code = strings.TrimSpace(c.Code)
+	c.Enabled = req.Enabled == nil || *req.Enabled
+	if c.Code == "" {
+		http.Error(w, "code required", http.StatusBadRequest)
+		return
+	}
+	if _, taken := coins.ByCode(c.Code); taken {
+		http.Error(w, "code already in use", http.StatusConflict)
+		return
+	}
+	if _, taken := coins.ByID(c.ID); taken {
+		http.Error(w, "id already in use", http.StatusConflict)
+		return
+	}
+	saved, err := db.InsertCurrency(c)
+	if errors.Is(err, db.ErrCurrencyCodeTaken) {
+		// added since the registry was loaded
+		http.Error(w, "code already in use", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[Coins] insert %s error: %v", c.Code, err)
+		http.Error(w, "could not create coin", http.StatusInternalServerError)
+		return
+	}
+	refreshCoins()
+	log.Printf("[Coins] added %s (id %d)", saved.Code, saved.ID)
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+func patchCoin(w http.ResponseWriter, r *http.Request, id int) {
+	var p coinPatch
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	c, ok := coins.ByID(id)
+	if !ok {
+		http.Error(w, "coin not found", http.StatusNotFound)
+		return
+	}
+	if p.Code != nil && strings.TrimSpace(*p.Code) != c.Code {
+		// weight profiles and per-coin source weights are keyed by code
+		http.Error(w, "code cannot be changed; add a new coin instead", http.StatusBadRequest)
+		return
+	}
+	if p.Subreddit != nil {
+		c.Subreddit = *p.Subreddit
+	}
+	if p.Enabled != nil {
+		c.Enabled = *p.Enabled
+	}
+	found, err := db.UpdateCurrency(c)
+	if err != nil {
+		log.Printf("[Coins] update %d error: %v", id, err)
+		http.Error(w, "could not update coin", http.StatusInternalServerError)
+		return
+	}
+	refreshCoins()
+	if !found {
+		http.Error(w, "coin not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("[Coins] updated %s (id %d, enabled=%t)", c.Code, c.ID, c.Enabled)
+	writeJSON(w, http.StatusOK, c)
+}
+
+func deleteCoin(w http.ResponseWriter, id int) {
+	found, err := db.DeleteCurrency(id)
+	if errors.Is(err, db.ErrCurrencyInUse) {
+		http.Error(w, "coin has messages or aggregates; disable it instead", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[Coins] delete %d error: %v", id, err)
+		http.Error(w, "could not delete coin", http.StatusInternalServerError)
+		return
+	}
+	refreshCoins()
+	if !found {
+		http.Error(w, "coin not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("[Coins] deleted id %d", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// refreshCoins reloads the registry after a change; on failure the
+// periodic refresh catches up.
+func refreshCoins() {
+	if err := coins.Refresh(); err != nil {
+		log.Printf("[Coins] refresh error: %v", err)
+	}
+}
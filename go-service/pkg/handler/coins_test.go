@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/coins"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+func TestCoinsRejectsBadChanges(t *testing.T) {
+	withAdminToken(t, "secret")
+	coins.Load([]db.Currency{{ID: 1, Code: "BTC", Enabled: true}, {ID: 2, Code: "ETH", Enabled: true}})
+
+	for _, tc := range []struct {
+		name, method, path, body string
+		code                     int
+		want                     string
+	}{
+		{"rename", http.MethodPatch, "/coins/1", `{"code":"XBT"}`, http.StatusBadRequest, "code cannot be changed"},
+		{"rename to taken", http.MethodPatch, "/coins/1", `{"code":"ETH"}`, http.StatusBadRequest, "code cannot be changed"},
+		{"unknown coin", http.MethodPatch, "/coins/9", `{"enabled":false}`, http.StatusNotFound, "coin not found"},
+		{"taken code", http.MethodPost, "/coins", `{"code":"ETH"}`, http.StatusConflict, "code already in use"},
+		{"taken id", http.MethodPost, "/coins", `{"id":2,"code":"SOL"}`, http.StatusConflict, "id already in use"},
+		{"no code", http.MethodPost, "/coins", `{"subreddit":"solana"}`, http.StatusBadRequest, "code required"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("X-Admin-Token", "secret")
+		rec := httptest.NewRecorder()
+		CoinsHandler(rec, req)
+		if rec.Code != tc.code || !strings.Contains(rec.Body.String(), tc.want) {
+			t.Errorf("%s: %d %q, want %d mentioning %q", tc.name, rec.Code, rec.Body.String(), tc.code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/aggregate"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/market"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// Limits on the lags one correlation request may ask for.
+const (
+	defaultCorrelationLags = 6
+	maxCorrelationLags     = 48
+)
+
+type correlationResponse struct {
+	Coin       string    `json:"coin"`
+	Strategy   string    `json:"strategy"`
+	Resolution string    `json:"resolution"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	// SentimentPoints and ReturnPoints count the windows each series has
+	// in the range.
+	SentimentPoints int `json:"sentiment_points"`
+	ReturnPoints    int `json:"return_points"`
+	market.Correlation
+	Lags []market.LagCorrelation `json:"lags"`
+}
+
+// CorrelationHandler serves GET /correlation, relating a coin's stored
+// sentiment to the returns of its imported price candles.
+// Query: token=BTC  resolution=1h  strategy=weighted_mean  max_lag=6
+//
+//	start_time / end_time (RFC3339, default the last 7 days)
+//
+// A positive lag pairs sentiment with the return that many windows later.
+func CorrelationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+
+	// 1) Parse parameters
+	coin, ok := coinByCode(q.Get("token"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown coin %q", q.Get("token")), http.StatusBadRequest)
+		return
+	}
+	res, err := model.LookupResolution(q.Get("resolution"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	agg, err := model.LookupAggregator(q.Get("strategy"))
+	if err == nil {
+		err = aggregate.CheckScheduled(agg.Name())
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	maxLag := defaultCorrelationLags
+	if s := q.Get("max_lag"); s != "" {
+		if maxLag, err = strconv.Atoi(s); err != nil || maxLag < 0 || maxLag > maxCorrelationLags {
+			http.Error(w, fmt.Sprintf("max_lag must be between 0 and %d", maxCorrelationLags), http.StatusBadRequest)
+			return
+		}
+	}
+	end := time.Now().UTC()
+	start := end.Add(-7 * 24 * time.Hour)
+	if s := q.Get("start_time"); s != "" {
+		if start, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, "bad start_time", http.StatusBadRequest)
+			return
+		}
+	}
+	if e := q.Get("end_time"); e != "" {
+		if end, err = time.Parse(time.RFC3339, e); err != nil {
+			http.Error(w, "bad end_time", http.StatusBadRequest)
+			return
+		}
+	}
+	if !start.Before(end) {
+		http.Error(w, "start_time must be before end_time", http.StatusBadRequest)
+		return
+	}
+
+	// 2) Load the sentiment series, skipping carried-forward windows
+	series := db.Series{Strategy: agg.Name(), Resolution: res.Name}
+	rows, err := db.FetchAggregatedSentimentsBetween(start, end, series)
+	if err != nil {
+		log.Printf("[Correlation] fetch sentiment: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	sentiment := map[time.Time]float64{}
+	for _, a := range rows {
+		if a.CurrencyID == coin.ID && a.Stats.Count > 0 && a.WindowStart.Before(end) {
+			sentiment[a.WindowStart.UTC()] = a.SentimentScore
+		}
+	}
+
+	// 3) Load candles wide enough for the previous close and every lag
+	pad := time.Duration(maxLag+1) * res.Step
+	candles, err := db.FetchCandlesBetween(coin.ID, start.Add(-pad), end.Add(pad))
+	if err != nil {
+		log.Printf("[Correlation] fetch candles: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	returns := market.Returns(candles, res.Step)
+
+	resp := correlationResponse{
+		Coin:            coin.Code,
+		Strategy:        agg.Name(),
+		Resolution:      res.Name,
+		Start:           start.UTC(),
+		End:             end.UTC(),
+		SentimentPoints: len(sentiment),
+		Correlation:     market.Correlate(sentiment, returns, res.Step, 0),
+		Lags:            market.CrossCorrelate(sentiment, returns, res.Step, maxLag),
+	}
+	for t := range returns {
+		if !t.Before(start) && t.Before(end) {
+			resp.ReturnPoints++
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
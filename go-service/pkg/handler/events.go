@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/coins"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/events"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// Limits on the events one request returns.
+const (
+	defaultEventLimit = 100
+	maxEventLimit     = 1000
+)
+
+// eventEntry is a stored event with its coin code.
+type eventEntry struct {
+	Coin string `json:"coin"`
+	db.SentimentEvent
+}
+
+func newEventEntry(e db.SentimentEvent) eventEntry {
+	code := strconv.Itoa(e.CurrencyID)
+	if c, ok := coins.ByID(e.CurrencyID); ok {
+		code = c.Code
+	}
+	return eventEntry{Coin: code, SentimentEvent: e}
+}
+
+// EventsHandler serves GET /events with detected sentiment anomalies,
+// newest first.
+// Query: tokens=SOL,BTC  resolution=5m  min_severity=major  limit=100
+//
+//	start_time / end_time (RFC3339, default the last 24 hours)
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+
+	// 1) Parse filters
+	f := db.EventFilter{End: time.Now().UTC(), Limit: defaultEventLimit}
+	f.Start = f.End.Add(-24 * time.Hour)
+	var err error
+	if s := q.Get("start_time"); s != "" {
+		if f.Start, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, "bad start_time", http.StatusBadRequest)
+			return
+		}
+	}
+	if e := q.Get("end_time"); e != "" {
+		if f.End, err = time.Parse(time.RFC3339, e); err != nil {
+			http.Error(w, "bad end_time", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := q.Get("resolution"); s != "" {
+		res, err := model.LookupResolution(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Resolution = res.Name
+	}
+	if s := q.Get("tokens"); s != "" {
+		ids, err := parseTokenFilter(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for id := range ids {
+			f.CoinIDs = append(f.CoinIDs, id)
+		}
+	}
+	if s := q.Get("min_severity"); s != "" {
+		if f.Severities, err = severitiesFrom(s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if s := q.Get("limit"); s != "" {
+		if f.Limit, err = strconv.Atoi(s); err != nil || f.Limit < 1 || f.Limit > maxEventLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxEventLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// 2) Fetch and answer
+	found, err := db.FetchSentimentEvents(f)
+	if err != nil {
+		log.Printf("[Events] fetch error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]eventEntry, 0, len(found))
+	for _, e := range found {
+		resp = append(resp, newEventEntry(e))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// severitiesFrom returns min and every severity above it.
+func severitiesFrom(min string) ([]string, error) {
+	for i, s := range events.Severities {
+		if s == min {
+			return events.Severities[i:], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown severity %q (want one of %v)", min, events.Severities)
+}
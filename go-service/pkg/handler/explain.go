@@ -80,7 +80,7 @@ func ExplainSentimentHandler(w http.ResponseWriter, r *http.Request) {
 
     // 5) Call OpenAI
     chatReq := gpt.ChatCompletionNewParams{
-        Model: "gpt-4.1-nano", // or "gpt-4.1-nano" if available
+        Model: oai.Model,
         Messages: []gpt.ChatCompletionMessageParamUnion{
             gpt.SystemMessage("You are a helpful assistant that explains sentiment."),
             gpt.UserMessage(sb.String()),
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// feedKey identifies one live series that streaming clients share.
+type feedKey struct {
+	Strategy   string
+	Resolution string
+}
+
+// feedData is one fetch of a feed: every coin's rows for the rolling
+// window [Start, End], plus earlier rows back to From for indicators to
+// warm up. It is shared between subscribers and must not be modified.
+type feedData struct {
+	Start, End time.Time
+	From       time.Time
+	Rows       []db.AggregatedSentiment
+	// Lookback is how many windows before Start the rows reach.
+	Lookback int
+}
+
+// feed is the state of one feedKey: its subscribers, each with the
+// lookback it needs, and the latest fetch.
+type feed struct {
+	subs map[chan *feedData]int
+	last *feedData
+}
+
+// lookback is the longest lookback any subscriber of f needs. The hub's
+// lock must be held.
+func (f *feed) lookback() int {
+	n := 0
+	for _, lb := range f.subs {
+		if lb > n {
+			n = lb
+		}
+	}
+	return n
+}
+
+// hub fetches each live feed once per tick and fans the result out to
+// every connection subscribed to it, so open dashboards share one query
+// instead of running one each. Custom fixed windows do not go through
+// the hub.
+type hub struct {
+	mu    sync.Mutex
+	feeds map[feedKey]*feed
+	// maxAge is how old a cached fetch may be to serve a new subscriber.
+	maxAge time.Duration
+}
+
+func newHub(maxAge time.Duration) *hub {
+	return &hub{feeds: map[feedKey]*feed{}, maxAge: maxAge}
+}
+
+// liveHub is the hub behind /ws.
+var liveHub = newHub(time.Minute)
+
+// StartHub refreshes every subscribed feed each interval until ctx is
+// done. Feeds that NotifyAggregates refreshed more recently than half an
+// interval ago are skipped, so with notifications flowing the ticker is
+// only a fallback.
+func StartHub(ctx context.Context, interval time.Duration) {
+	liveHub.maxAge = interval
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("[Hub] stopped")
+				return
+			case <-ticker.C:
+				liveHub.refresh(interval / 2)
+			}
+		}
+	}()
+	log.Printf("[Hub] broadcasting live feeds every %s", interval)
+}
+
+// subscribe registers a subscriber to key that needs lookback windows of
+// history and returns the channel its updates arrive on. Only the latest
+// update is kept for a subscriber that falls behind. cancel unsubscribes.
+func (h *hub) subscribe(key feedKey, lookback int) (updates <-chan *feedData, cancel func()) {
+	ch := make(chan *feedData, 1)
+	h.mu.Lock()
+	f := h.feeds[key]
+	if f == nil {
+		f = &feed{subs: map[chan *feedData]int{}}
+		h.feeds[key] = f
+	}
+	f.subs[ch] = lookback
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(f.subs, ch)
+			if len(f.subs) == 0 {
+				delete(h.feeds, key)
+			}
+		})
+	}
+}
+
+// current returns the latest fetch of key if it is fresh and reaches back
+// lookback windows, or fetches and caches a new one.
+func (h *hub) current(key feedKey, lookback int) (*feedData, error) {
+	h.mu.Lock()
+	if f := h.feeds[key]; f != nil && f.last != nil &&
+		f.last.Lookback >= lookback && time.Since(f.last.End) < h.maxAge {
+		data := f.last
+		h.mu.Unlock()
+		return data, nil
+	}
+	h.mu.Unlock()
+
+	data, err := fetchFeed(key, lookback)
+	if err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	if f := h.feeds[key]; f != nil {
+		f.last = data
+	}
+	h.mu.Unlock()
+	return data, nil
+}
+
+// NotifyAggregates refreshes the live feed of the series n was written
+// to right away, so subscribers see new aggregates without waiting for
+// the next tick.
+func NotifyAggregates(n db.AggregateNotice) {
+	liveHub.refreshFeed(feedKey{Strategy: n.Strategy, Resolution: n.Resolution})
+}
+
+// refresh fetches every feed that has subscribers and was not fetched in
+// the last fresh, once each, and hands the result to all of them.
+func (h *hub) refresh(fresh time.Duration) {
+	h.mu.Lock()
+	lookbacks := make(map[feedKey]int, len(h.feeds))
+	for key, f := range h.feeds {
+		if f.last != nil && time.Since(f.last.End) < fresh {
+			continue
+		}
+		lookbacks[key] = f.lookback()
+	}
+	h.mu.Unlock()
+
+	for key, lookback := range lookbacks {
+		data, err := fetchFeed(key, lookback)
+		if err != nil {
+			log.Printf("[Hub] %s/%s fetch error: %v", key.Strategy, key.Resolution, err)
+			continue
+		}
+		h.publish(key, data)
+	}
+}
+
+// refreshFeed fetches key now if anyone subscribes to it.
+func (h *hub) refreshFeed(key feedKey) {
+	h.mu.Lock()
+	f := h.feeds[key]
+	if f == nil {
+		h.mu.Unlock()
+		return
+	}
+	lookback := f.lookback()
+	h.mu.Unlock()
+
+	data, err := fetchFeed(key, lookback)
+	if err != nil {
+		log.Printf("[Hub] %s/%s fetch error: %v", key.Strategy, key.Resolution, err)
+		return
+	}
+	h.publish(key, data)
+}
+
+// publish caches data as key's latest fetch and delivers it to every
+// subscriber, replacing an update it has not picked up yet.
+func (h *hub) publish(key feedKey, data *feedData) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := h.feeds[key]
+	if f == nil {
+		return
+	}
+	f.last = data
+	for ch := range f.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- data
+	}
+	log.Printf("[Hub] %s/%s: %d rows to %d subscribers", key.Strategy, key.Resolution, len(data.Rows), len(f.subs))
+}
+
+// window loads key's rows for the fixed window [start, end]. Fixed
+// windows are neither cached nor shared.
+func (h *hub) window(key feedKey, res model.Resolution, start, end time.Time, lookback int) (*feedData, error) {
+	return fetchWindow(key, res, start, end, lookback)
+}
+
+// fetchFeed loads the rolling window of key ending now.
+func fetchFeed(key feedKey, lookback int) (*feedData, error) {
+	res, err := model.LookupResolution(key.Resolution)
+	if err != nil {
+		return nil, err
+	}
+	end := time.Now().UTC()
+	start := end.Add(-defaultWindowBuckets * res.Step).Truncate(res.Step)
+	return fetchWindow(key, res, start, end, lookback)
+}
+
+// fetchWindow loads key's rows for [start, end] and lookback windows
+// before start.
+func fetchWindow(key feedKey, res model.Resolution, start, end time.Time, lookback int) (*feedData, error) {
+	from := start.Add(-time.Duration(lookback) * res.Step)
+	rows, err := db.FetchAggregatedSentimentsBetween(from, end,
+		db.Series{Strategy: key.Strategy, Resolution: key.Resolution})
+	if err != nil {
+		return nil, err
+	}
+	return &feedData{Start: start, End: end, From: from, Rows: rows, Lookback: lookback}, nil
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/aggregate"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// maxIndicatorBuckets bounds the buckets one indicator request covers,
+// lookback included.
+const maxIndicatorBuckets = 5000
+
+type indicatorBucket struct {
+	Time       string                         `json:"time"`
+	Coins      map[string]float64             `json:"coins"`
+	Indicators map[string]map[string]*float64 `json:"indicators"`
+}
+
+type indicatorsResponse struct {
+	Strategy   string            `json:"strategy"`
+	Resolution string            `json:"resolution"`
+	Indicators []string          `json:"indicators"`
+	Buckets    []indicatorBucket `json:"buckets"`
+}
+
+// IndicatorsHandler serves GET /indicators with derived series computed
+// from stored sentiment.
+// Query: tokens=SOL,BTC  resolution=5m  strategy=weighted_mean
+//
+//	indicators=sma:12,ema:26,momentum:10,roc:10,bollinger:20:2,rsi:14
+//	start_time / end_time (RFC3339, default the last 24 hours)
+//
+// Values still warming up at the start of the stored history are null.
+func IndicatorsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+
+	// 1) Parse parameters
+	res, err := model.LookupResolution(q.Get("resolution"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	agg, err := model.LookupAggregator(q.Get("strategy"))
+	if err == nil {
+		err = aggregate.CheckScheduled(agg.Name())
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	inds, err := model.ParseIndicators(strings.Split(q.Get("indicators"), ","))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(inds) == 0 {
+		http.Error(w, "indicators is required", http.StatusBadRequest)
+		return
+	}
+	coinIDs, err := parseTokenFilter(q.Get("tokens"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end := time.Now().UTC()
+	start := end.Add(-24 * time.Hour)
+	if s := q.Get("start_time"); s != "" {
+		if start, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, "bad start_time", http.StatusBadRequest)
+			return
+		}
+	}
+	if e := q.Get("end_time"); e != "" {
+		if end, err = time.Parse(time.RFC3339, e); err != nil {
+			http.Error(w, "bad end_time", http.StatusBadRequest)
+			return
+		}
+	}
+	start = start.UTC().Truncate(res.Step)
+	end = end.UTC()
+	if !start.Before(end) {
+		http.Error(w, "start_time must be before end_time", http.StatusBadRequest)
+		return
+	}
+	if n := int(end.Sub(start)/res.Step) + maxLookback(inds); n > maxIndicatorBuckets {
+		http.Error(w, fmt.Sprintf("range covers %d buckets, at most %d allowed", n, maxIndicatorBuckets), http.StatusBadRequest)
+		return
+	}
+
+	// 2) Compute
+	series := db.Series{Strategy: agg.Name(), Resolution: res.Name}
+	scores, values, err := computeIndicators(series, res, coinIDs, inds, start, end)
+	if err != nil {
+		log.Printf("[Indicators] %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// 3) Assemble one bucket per window
+	resp := indicatorsResponse{Strategy: agg.Name(), Resolution: res.Name, Buckets: []indicatorBucket{}}
+	for _, ind := range inds {
+		resp.Indicators = append(resp.Indicators, ind.Name())
+	}
+	for t := start; t.Before(end); t = t.Add(res.Step) {
+		b := indicatorBucket{
+			Time:       t.Format(time.RFC3339),
+			Coins:      map[string]float64{},
+			Indicators: map[string]map[string]*float64{},
+		}
+		for _, code := range coinIDs {
+			if s, ok := scores[t][code]; ok {
+				b.Coins[code] = s
+			}
+			if v, ok := values[t][code]; ok {
+				b.Indicators[code] = v
+			}
+		}
+		resp.Buckets = append(resp.Buckets, b)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// maxLookback is the longest warm-up any of inds needs.
+func maxLookback(inds []model.Indicator) int {
+	n := 0
+	for _, ind := range inds {
+		if l := ind.Lookback(); l > n {
+			n = l
+		}
+	}
+	return n
+}
+
+// computeIndicators loads the stored series of every coin in coins (ID →
+// code), with enough earlier windows for inds to warm up, and returns
+// indicatorValues over [start, end).
+func computeIndicators(series db.Series, res model.Resolution, coins map[int]string, inds []model.Indicator, start, end time.Time) (map[time.Time]map[string]float64, map[time.Time]map[string]map[string]*float64, error) {
+	from := start.Add(-time.Duration(maxLookback(inds)) * res.Step)
+	rows, err := db.FetchAggregatedSentimentsBetween(from, end, series)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch %s rows: %w", series.Resolution, err)
+	}
+	scores, values := indicatorValues(rows, res, coins, inds, start, end)
+	return scores, values, nil
+}
+
+// indicatorValues computes inds over rows, one series' rows for every
+// coin in coins (ID → code) reaching back far enough for inds to warm up,
+// and returns the score and the indicator values per window in
+// [start, end) and coin code. A window without a stored row repeats the
+// score before it; windows before a coin's first row are left out.
+func indicatorValues(rows []db.AggregatedSentiment, res model.Resolution, coins map[int]string, inds []model.Indicator, start, end time.Time) (map[time.Time]map[string]float64, map[time.Time]map[string]map[string]*float64) {
+	byCoin := map[int]map[time.Time]float64{}
+	for _, a := range rows {
+		if _, ok := coins[a.CurrencyID]; !ok || !a.WindowStart.Before(end) {
+			continue
+		}
+		if byCoin[a.CurrencyID] == nil {
+			byCoin[a.CurrencyID] = map[time.Time]float64{}
+		}
+		byCoin[a.CurrencyID][a.WindowStart.UTC()] = a.SentimentScore
+	}
+
+	scores := map[time.Time]map[string]float64{}
+	values := map[time.Time]map[string]map[string]*float64{}
+	for id, stored := range byCoin {
+		code := coins[id]
+		// a gap-free timeline from the coin's first stored window
+		first := end
+		for t := range stored {
+			if t.Before(first) {
+				first = t
+			}
+		}
+		var (
+			times []time.Time
+			xs    []float64
+		)
+		last := 0.0
+		for t := first; t.Before(end); t = t.Add(res.Step) {
+			if s, ok := stored[t]; ok {
+				last = s
+			}
+			times = append(times, t)
+			xs = append(xs, last)
+		}
+
+		outputs := map[string][]float64{}
+		for _, ind := range inds {
+			for name, ys := range ind.Compute(xs) {
+				outputs[name] = ys
+			}
+		}
+		for i, t := range times {
+			if t.Before(start) {
+				continue
+			}
+			if scores[t] == nil {
+				scores[t] = map[string]float64{}
+				values[t] = map[string]map[string]*float64{}
+			}
+			scores[t][code] = xs[i]
+			v := make(map[string]*float64, len(outputs))
+			for name, ys := range outputs {
+				if y := ys[i]; !math.IsNaN(y) {
+					v[name] = &y
+				} else {
+					v[name] = nil
+				}
+			}
+			values[t][code] = v
+		}
+	}
+	return scores, values
+}
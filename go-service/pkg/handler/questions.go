@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+type questionEntry struct {
+	config.Question
+	// Weight is the question's weight in the default profile.
+	Weight float64 `json:"weight"`
+}
+
+// QuestionsHandler serves GET /questions with the question registry, so
+// clients can label topic series without hard-coding them.
+func QuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	weights := model.Weights()
+	out := make([]questionEntry, 0, len(config.Questions))
+	for _, q := range config.Questions {
+		out = append(out, questionEntry{Question: q, Weight: weights[q.ID]})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/aggregate"
+)
+
+// maxRecomputeSpan bounds the time range of one recompute request.
+const maxRecomputeSpan = 7 * 24 * time.Hour
+
+// RecomputeRequest selects the coins and range to recompute. Of the
+// AggregateRequest fields only the range and strategy apply: the
+// resolution is ignored since every resolution is recomputed, and the
+// overrides are rejected since the result replaces the stored series.
+type RecomputeRequest struct {
+	AggregateRequest
+	// Tokens limits the recompute to these coin codes; empty means all.
+	Tokens []string `json:"tokens"`
+}
+
+// RecomputeHandler handles POST /aggregate/recompute. Unlike /aggregate,
+// which never touches stored windows, it overwrites the windows whose
+// result changed and answers with a report of every replaced window.
+func RecomputeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req RecomputeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	opts, err := req.options()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.Overridden() {
+		http.Error(w, "recompute takes no filters, decay, sources, authors or weight_profile overrides", http.StatusBadRequest)
+		return
+	}
+	start, end, err := parseRecomputeRange(req.StartTime, req.EndTime)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	coins, err := recomputeCoins(req.Tokens)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("[Recompute] %d coins %s → %s", len(coins),
+		start.Format(time.RFC3339), end.Format(time.RFC3339))
+	report, err := aggregate.Recompute(coins, start, end, opts)
+	if err != nil {
+		log.Printf("[Recompute] error: %v", err)
+		http.Error(w, "recompute failed", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// parseRecomputeRange requires both bounds, in order and at most
+// maxRecomputeSpan apart.
+func parseRecomputeRange(startStr, endStr string) (time.Time, time.Time, error) {
+	if startStr == "" || endStr == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("start_time and end_time are required")
+	}
+	start, err := time.Parse(time.RFC3339, startStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("bad start_time: %v", err)
+	}
+	end, err := time.Parse(time.RFC3339, endStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("bad end_time: %v", err)
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start_time must be before end_time")
+	}
+	if end.Sub(start) > maxRecomputeSpan {
+		return time.Time{}, time.Time{}, fmt.Errorf("range exceeds %s", maxRecomputeSpan)
+	}
+	return start.UTC(), end.UTC(), nil
+}
+
+// recomputeCoins resolves codes to coins; no codes selects every coin.
+func recomputeCoins(codes []string) ([]aggregate.Coin, error) {
+	if len(codes) == 0 {
+		return AggregationCoins(), nil
+	}
+	out := make([]aggregate.Coin, 0, len(codes))
+	for _, code := range codes {
+		c, ok := coinByCode(code)
+		if !ok {
+			return nil, fmt.Errorf("unknown token %q", code)
+		}
+		out = append(out, aggregate.Coin{ID: c.ID, Code: c.Code})
+	}
+	return out, nil
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/coins"
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+// withAdminToken installs an admin token for the test.
+func withAdminToken(t *testing.T, token string) {
+	t.Helper()
+	prev := config.Current
+	t.Cleanup(func() { config.Current = prev })
+	config.Current.Server.AdminToken = token
+}
+
+func TestRecomputeRejectsBadRequests(t *testing.T) {
+	withAdminToken(t, "secret")
+	coins.Load([]db.Currency{{ID: 1, Code: "BTC", Enabled: true}})
+	const span = `"start_time":"2026-01-01T00:00:00Z","end_time":"2026-01-02T00:00:00Z"`
+
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"decay override", `{` + span + `,"decay":{"half_life":"1h"}}`, "overrides"},
+		{"sources override", `{` + span + `,"sources":{"reddit":0.5}}`, "overrides"},
+		{"no range", `{"strategy":"weighted_mean"}`, "start_time and end_time are required"},
+		{"too long", `{"start_time":"2026-01-01T00:00:00Z","end_time":"2026-03-01T00:00:00Z"}`, "range exceeds"},
+		{"unknown token", `{` + span + `,"tokens":["NOPE"]}`, `unknown token "NOPE"`},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/aggregate/recompute", strings.NewReader(tc.body))
+		req.Header.Set("X-Admin-Token", "secret")
+		rec := httptest.NewRecorder()
+		RecomputeHandler(rec, req)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tc.want) {
+			t.Errorf("%s: %d %q, want 400 mentioning %q", tc.name, rec.Code, rec.Body.String(), tc.want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/coins"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/events"
+)
+
+const (
+	// streamKeepAlive is how often an idle /stream connection gets a
+	// comment line, so proxies do not time it out.
+	streamKeepAlive = 15 * time.Second
+
+	// streamRetry is the reconnect delay /stream suggests to clients.
+	streamRetry = 5 * time.Second
+)
+
+// StreamHandler serves GET /stream, the /ws feed as Server-Sent Events
+// for clients that cannot open a WebSocket. It takes the same query
+// params as WSHandler and sends the same payloads, one per SSE event:
+//
+//	snapshot  the whole window, on connect
+//	delta     the buckets that are new or changed since the last one
+//	event     a detected anomaly for a streamed coin and resolution
+//
+// The id of a snapshot or delta is the time of the newest bucket that
+// holds a stored row for a streamed coin. Buckets after it may still be
+// open or waiting on the aggregator, so the id never moves past them. A
+// client that reconnects with that id in Last-Event-ID (as browsers do
+// on their own) gets a delta with every bucket from that time on
+// instead of a fresh snapshot, including the ones filled while it was
+// away. An id older than the start of the window gets a snapshot, since
+// the buckets in between are gone. A payload without such a bucket
+// carries no id.
+func StreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sub, qerr := subscriptionFromQuery(r.URL.Query())
+	if qerr != nil {
+		http.Error(w, qerr.Message, http.StatusBadRequest)
+		return
+	}
+	var since time.Time
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		t, err := time.Parse(bucketTimeFormat, id)
+		if err != nil {
+			log.Printf("[Stream] ignoring bad Last-Event-ID %q", id)
+		} else {
+			since = t
+		}
+	}
+
+	eventCh, stopEvents := events.Subscribe()
+	defer stopEvents()
+	serveStream(w, r, liveHub, eventCh, sub, since)
+}
+
+// serveStream streams sub until the client goes away. With since set
+// inside the window, the first payload is a delta of the buckets from
+// since on rather than a snapshot.
+func serveStream(w http.ResponseWriter, r *http.Request, feeds feedSource, eventCh <-chan db.SentimentEvent, sub wsSubscription, since time.Time) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // keep nginx from buffering events
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	flusher.Flush()
+	log.Printf("[Stream] client connected: coins=%v strategy=%s resolution=%s fixed=%v resume=%v",
+		sub.codes(), sub.Strategy, sub.Resolution.Name, sub.Fixed, !since.IsZero())
+
+	var liveCh <-chan *feedData
+	if !sub.Fixed {
+		ch, cancel := feeds.subscribe(sub.feedKey(), maxLookback(sub.Indicators))
+		defer cancel()
+		liveCh = ch
+	} else {
+		log.Printf("[Stream] using fixed window %s → %s", sub.Start, sub.End)
+	}
+
+	// last JSON sent per bucket time, to find what a delta must carry
+	sent := map[string][]byte{}
+	send := func(snapshot bool, feed *feedData) error {
+		resp := bucketPayload(sub, feed)
+		current, changed, err := diffBuckets(sent, resp)
+		if err != nil {
+			return err
+		}
+		typ, buckets := "delta", changed
+		switch {
+		case snapshot && !since.IsZero() && !since.Before(feed.Start):
+			// resume: only what the client may have missed
+			buckets = make([]map[string]interface{}, 0, len(resp))
+			for _, entry := range resp {
+				if t, err := time.Parse(bucketTimeFormat, entry["time"].(string)); err == nil && !t.Before(since) {
+					buckets = append(buckets, entry)
+				}
+			}
+		case snapshot:
+			typ, buckets = "snapshot", resp
+		case len(changed) == 0:
+			return nil
+		}
+		id := lastStoredBucket(sub, feed)
+		frame := map[string]interface{}{
+			"v":       wsProtocolVersion,
+			"type":    typ,
+			"window":  map[string]string{"start": feed.Start.Format(time.RFC3339), "end": feed.End.Format(time.RFC3339)},
+			"buckets": buckets,
+		}
+		if err := writeSSE(w, typ, id, frame); err != nil {
+			return err
+		}
+		flusher.Flush()
+		sent = current
+		return nil
+	}
+
+	if feed, err := loadWindow(feeds, sub); err != nil {
+		log.Printf("[Stream] fetch error: %v", err)
+	} else if err := send(true, feed); err != nil {
+		log.Printf("[Stream] write error: %v", err)
+		return
+	}
+
+	poll := time.NewTicker(fixedWindowPoll)
+	defer poll.Stop()
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			log.Println("[Stream] client disconnected")
+			return
+		case feed := <-liveCh:
+			err = send(false, feed)
+		case <-poll.C:
+			if !sub.Fixed {
+				continue
+			}
+			feed, ferr := loadWindow(feeds, sub)
+			if ferr != nil {
+				log.Printf("[Stream] fetch error: %v", ferr)
+				continue
+			}
+			err = send(false, feed)
+		case e := <-eventCh:
+			entry := newEventEntry(e)
+			if e.Resolution != sub.Resolution.Name || !sub.streams(entry.Coin) {
+				continue
+			}
+			frame := map[string]interface{}{"v": wsProtocolVersion, "type": "event", "event": entry}
+			if err = writeSSE(w, "event", "", frame); err == nil {
+				flusher.Flush()
+			}
+		case <-keepAlive.C:
+			if _, err = io.WriteString(w, ": keepalive\n\n"); err == nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			log.Printf("[Stream] write error: %v", err)
+			return
+		}
+	}
+}
+
+// lastStoredBucket returns the time of the newest bucket in feed's
+// window that has a stored row for a coin sub streams, in the format
+// of the bucket times, or "" if there is none.
+func lastStoredBucket(sub wsSubscription, feed *feedData) string {
+	streamed := make(map[string]bool)
+	for _, code := range sub.codes() {
+		streamed[code] = true
+	}
+	var last time.Time
+	for _, a := range feed.Rows {
+		if a.WindowStart.Before(feed.Start) || !a.WindowStart.After(last) {
+			continue
+		}
+		code := strconv.Itoa(a.CurrencyID)
+		if c, ok := coins.ByID(a.CurrencyID); ok {
+			code = c.Code
+		}
+		if streamed[code] {
+			last = a.WindowStart
+		}
+	}
+	if last.IsZero() {
+		return ""
+	}
+	return last.UTC().Truncate(time.Minute).Format(bucketTimeFormat)
+}
+
+// writeSSE writes v as one SSE event. An empty id leaves the client's
+// last event ID as it was.
+func writeSSE(w io.Writer, event, id string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
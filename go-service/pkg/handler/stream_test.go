@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/coins"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+// sseEvent is one parsed Server-Sent Event.
+type sseEvent struct {
+	id, event string
+	data      map[string]interface{}
+}
+
+// openStream starts serveStream on feeds behind a test server and
+// returns a reader over the response body.
+func openStream(t *testing.T, feeds feedSource, query, lastEventID string) *bufio.Reader {
+	t.Helper()
+	coins.Load([]db.Currency{
+		{ID: 1, Code: "BTC", Enabled: true},
+		{ID: 2, Code: "ETH", Enabled: true},
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub, qerr := subscriptionFromQuery(r.URL.Query())
+		if qerr != nil {
+			t.Errorf("query: %v", qerr)
+			return
+		}
+		since, _ := time.Parse(bucketTimeFormat, r.Header.Get("Last-Event-ID"))
+		serveStream(w, r, feeds, nil, sub, since)
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/stream?"+query, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// readEvent returns the next event, skipping comments and retry hints.
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && ev.event != "":
+			return ev
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data); err != nil {
+				t.Fatalf("decode data: %v", err)
+			}
+		}
+	}
+}
+
+func TestStreamSnapshotThenDelta(t *testing.T) {
+	feeds := newFakeFeeds(testFeed(0.1))
+	body := openStream(t, feeds, "tokens=BTC&resolution=5m", "")
+
+	snap := readEvent(t, body)
+	last := testStart.Add(10 * time.Minute).Format(bucketTimeFormat)
+	if snap.event != "snapshot" || snap.id != last {
+		t.Fatalf("got %s id %q, want snapshot id %q", snap.event, snap.id, last)
+	}
+	if got := bucketCoins(t, snap.data); len(got) != 1 || got[0] != "BTC" {
+		t.Fatalf("snapshot coins = %v, want [BTC]", got)
+	}
+
+	feeds.push(testFeed(0.1))
+	feeds.push(testFeed(0.9))
+	delta := readEvent(t, body)
+	if delta.event != "delta" || delta.id != last {
+		t.Fatalf("got %s id %q, want delta id %q", delta.event, delta.id, last)
+	}
+	if n := len(delta.data["buckets"].([]interface{})); n != 1 {
+		t.Fatalf("delta has %d buckets, want 1", n)
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	feeds := newFakeFeeds(testFeed(0.1))
+	since := testStart.Add(5 * time.Minute).Format(bucketTimeFormat)
+	body := openStream(t, feeds, "resolution=5m", since)
+
+	ev := readEvent(t, body)
+	if ev.event != "delta" {
+		t.Fatalf("resumed stream opened with %s, want delta", ev.event)
+	}
+	buckets := ev.data["buckets"].([]interface{})
+	if len(buckets) != 2 || buckets[0].(map[string]interface{})["time"] != since {
+		t.Fatalf("resume buckets = %v, want the 2 from %s on", buckets, since)
+	}
+}
+
+func TestStreamResumeBeforeWindow(t *testing.T) {
+	// the window has rolled past the client's last bucket
+	feeds := newFakeFeeds(testFeed(0.1))
+	since := testStart.Add(-time.Hour).Format(bucketTimeFormat)
+	body := openStream(t, feeds, "resolution=5m", since)
+
+	ev := readEvent(t, body)
+	if ev.event != "snapshot" {
+		t.Fatalf("resume from before the window opened with %s, want snapshot", ev.event)
+	}
+	if n := len(ev.data["buckets"].([]interface{})); n != 3 {
+		t.Fatalf("snapshot has %d buckets, want all 3", n)
+	}
+}
+
+// openFeed is testFeed with BTC's last row still missing and one more,
+// empty bucket at the end of the window.
+func openFeed() *feedData {
+	feed := testFeed(0.1)
+	feed.End = feed.End.Add(5 * time.Minute)
+	rows := feed.Rows[:0]
+	for _, a := range feed.Rows {
+		if a.CurrencyID != 1 || a.WindowStart.Before(testStart.Add(10*time.Minute)) {
+			rows = append(rows, a)
+		}
+	}
+	feed.Rows = rows
+	return feed
+}
+
+func TestStreamIDIsLastStoredBucket(t *testing.T) {
+	feeds := newFakeFeeds(openFeed())
+	body := openStream(t, feeds, "tokens=BTC&resolution=5m", "")
+
+	// ETH's row at 00:10 does not count: only BTC is streamed
+	stored := testStart.Add(5 * time.Minute).Format(bucketTimeFormat)
+	if snap := readEvent(t, body); snap.event != "snapshot" || snap.id != stored {
+		t.Fatalf("got %s id %q, want snapshot id %q", snap.event, snap.id, stored)
+	}
+
+	feeds.push(testFeed(0.9))
+	filled := testStart.Add(10 * time.Minute).Format(bucketTimeFormat)
+	if delta := readEvent(t, body); delta.event != "delta" || delta.id != filled {
+		t.Fatalf("got %s id %q, want delta id %q", delta.event, delta.id, filled)
+	}
+}
+
+func TestStreamResumeSendsBucketsFilledWhileAway(t *testing.T) {
+	// the client last saw id 00:05, before BTC's 00:10 row was stored
+	feeds := newFakeFeeds(testFeed(0.9))
+	since := testStart.Add(5 * time.Minute).Format(bucketTimeFormat)
+	body := openStream(t, feeds, "tokens=BTC&resolution=5m", since)
+
+	ev := readEvent(t, body)
+	buckets := ev.data["buckets"].([]interface{})
+	if ev.event != "delta" || len(buckets) != 2 {
+		t.Fatalf("got %s with %d buckets, want a delta with 2", ev.event, len(buckets))
+	}
+	last := buckets[1].(map[string]interface{})
+	if score := last["coins"].(map[string]interface{})["BTC"]; score != 0.9 {
+		t.Fatalf("bucket %v has BTC %v, want 0.9", last["time"], score)
+	}
+}
+
+func TestStreamRejectsUnknownCoin(t *testing.T) {
+	coins.Load([]db.Currency{{ID: 1, Code: "BTC", Enabled: true}})
+	rec := httptest.NewRecorder()
+	q := url.Values{"tokens": {"BTC,NOPE"}}
+	StreamHandler(rec, httptest.NewRequest(http.MethodGet, "/stream?"+q.Encode(), nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestStreamRejectsWideWindow(t *testing.T) {
+	coins.Load([]db.Currency{{ID: 1, Code: "BTC", Enabled: true}})
+	rec := httptest.NewRecorder()
+	q := url.Values{"resolution": {"1m"}, "start_time": {"2025-01-01T00:00:00Z"}, "end_time": {"2026-01-01T00:00:00Z"}}
+	StreamHandler(rec, httptest.NewRequest(http.MethodGet, "/stream?"+q.Encode(), nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "buckets") {
+		t.Fatalf("status = %d %q, want 400 about buckets", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/coins"
+	"github.com/cosmic-hash/CryptoPulse/pkg/config"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+type topicBucket struct {
+	Time   string                        `json:"time"`
+	Coins  map[string]map[string]float64 `json:"coins"`
+	Counts map[string]map[string]int     `json:"counts"`
+}
+
+type topicsResponse struct {
+	Resolution string            `json:"resolution"`
+	Questions  map[string]string `json:"questions"`
+	Buckets    []topicBucket     `json:"buckets"`
+}
+
+// TopicsHandler serves GET /topics with per-question sentiment series.
+// Query: tokens=SOL,BTC  questions=3,4  resolution=5m
+//        start_time / end_time (RFC3339, default the last 24 hours)
+func TopicsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+
+	// 1) Parse filters
+	res, err := model.LookupResolution(q.Get("resolution"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end := time.Now().UTC()
+	start := end.Add(-24 * time.Hour)
+	if s := q.Get("start_time"); s != "" {
+		if start, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, "bad start_time", http.StatusBadRequest)
+			return
+		}
+	}
+	if e := q.Get("end_time"); e != "" {
+		if end, err = time.Parse(time.RFC3339, e); err != nil {
+			http.Error(w, "bad end_time", http.StatusBadRequest)
+			return
+		}
+	}
+	coinIDs, err := parseTokenFilter(q.Get("tokens"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	questions, err := parseQuestionFilter(q.Get("questions"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 2) Fetch stored buckets
+	series := db.Series{Strategy: model.DefaultStrategy, Resolution: res.Name}
+	rows, err := db.FetchAggregatedSentimentsBetween(start.UTC().Truncate(res.Step), end.UTC(), series)
+	if err != nil {
+		log.Printf("[Topics] fetch error: %v", err)
+		http.Error(w, "db fetch failed", http.StatusInternalServerError)
+		return
+	}
+
+	// 3) Keep only fresh topic scores that pass the filters, oldest first
+	resp := topicsResponse{Resolution: res.Name, Questions: questions}
+	index := map[time.Time]int{}
+	for _, a := range rows {
+		code, ok := coinIDs[a.CurrencyID]
+		if !ok || len(a.Topics) == 0 {
+			continue
+		}
+		scores := map[string]float64{}
+		counts := map[string]int{}
+		for qid, score := range a.Topics {
+			if _, ok := questions[qid]; ok {
+				scores[qid] = score
+				counts[qid] = a.Stats.QuestionCounts[qid]
+			}
+		}
+		if len(scores) == 0 {
+			continue
+		}
+		i, ok := index[a.WindowStart]
+		if !ok {
+			i = len(resp.Buckets)
+			index[a.WindowStart] = i
+			resp.Buckets = append(resp.Buckets, topicBucket{
+				Time:   a.WindowStart.UTC().Format(time.RFC3339),
+				Coins:  map[string]map[string]float64{},
+				Counts: map[string]map[string]int{},
+			})
+		}
+		resp.Buckets[i].Coins[code] = scores
+		resp.Buckets[i].Counts[code] = counts
+	}
+
+	// 4) Return JSON
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("[Topics] JSON encode error: %v", err)
+	}
+}
+
+// parseTokenFilter turns "SOL,BTC" into coin ID → code. Empty means all coins.
+func parseTokenFilter(tokens string) (map[int]string, error) {
+	out := map[int]string{}
+	if tokens == "" {
+		for _, c := range coins.Enabled() {
+			out[c.ID] = c.Code
+		}
+		return out, nil
+	}
+	for _, code := range strings.Split(tokens, ",") {
+		c, ok := coinByCode(strings.TrimSpace(code))
+		if !ok {
+			return nil, fmt.Errorf("unknown coin %q", code)
+		}
+		out[c.ID] = c.Code
+	}
+	return out, nil
+}
+
+// parseQuestionFilter turns "3,4" into question ID → text. Empty or "all"
+// means every question.
+func parseQuestionFilter(ids string) (map[string]string, error) {
+	out := map[string]string{}
+	if ids == "" || ids == "all" {
+		for id, text := range config.QuestionMapping {
+			out[id] = text
+		}
+		return out, nil
+	}
+	for _, id := range strings.Split(ids, ",") {
+		id = strings.TrimSpace(id)
+		text, ok := config.QuestionMapping[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown question %q", id)
+		}
+		out[id] = text
+	}
+	return out, nil
+}
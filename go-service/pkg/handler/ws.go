@@ -3,13 +3,13 @@ package handlers
 import (
     "log"
     "net/http"
-    "sort"
     "strconv"
-    "strings"
     "time"
 
     "github.com/gorilla/websocket"
-    "github.com/cosmic-hash/CryptoPulse/pkg/db"
+    "github.com/cosmic-hash/CryptoPulse/pkg/coins"
+    "github.com/cosmic-hash/CryptoPulse/pkg/events"
+    "github.com/cosmic-hash/CryptoPulse/pkg/model"
 )
 
 // upgrader allows HTTP → WebSocket upgrade
@@ -17,18 +17,43 @@ var upgrader = websocket.Upgrader{
     CheckOrigin: func(r *http.Request) bool { return true },
 }
 
-// build a fast lookup from your global coinsList in api.go
-var currencyCodeMap = func() map[int]string {
-    m := make(map[int]string, len(coinsList))
-    for _, c := range coinsList {
-        m[c.ID] = c.Code
-    }
-    return m
-}()
-
-// WSHandler streams pre-aggregated sentiment in 5-minute buckets.
-// Supports both query-param and JSON overrides.
-// If no "tokens" key is sent, it will send all coins.
+// defaultWindowBuckets is how many buckets the rolling window covers
+// when the client does not pin start_time/end_time.
+const defaultWindowBuckets = 12
+
+// bucketTimeFormat is how bucket times appear in streamed payloads.
+const bucketTimeFormat = "2006-01-02T15:04Z"
+
+// WSHandler streams pre-aggregated sentiment in buckets of the requested
+// resolution (5 minutes by default).
+// The initial subscription comes from the query params (tokens, strategy,
+// resolution, topics, indicators, start_time, end_time); with no tokens
+// it streams every enabled coin.
+//
+// Clients change it with versioned JSON requests (see ws_protocol.go):
+//
+//	{"v":1,"type":"subscribe","id":"1","tokens":["BTC"],"resolution":"1h"}
+//	{"v":1,"type":"unsubscribe","id":"2","tokens":["BTC"]}
+//	{"v":1,"type":"set_window","id":"3","start_time":"...","end_time":"..."}
+//	{"v":1,"type":"set_options","id":"4","indicators":["sma:12"]}
+//	{"v":1,"type":"ping","id":"5"}
+//
+// Every request is answered by an ack (or pong) or an error frame with
+// the same "id"; a rejected request, such as one naming an unknown coin,
+// changes nothing. Fields a request leaves out keep their value.
+//
+// Every frame carries the protocol "v", a "type" and a per-connection
+// "seq" that grows by one with each frame:
+//
+//	snapshot  the whole window, on connect and after every change
+//	delta     only the buckets that are new or changed since the last
+//	          frame, with the current window so clients can drop older
+//	          buckets; nothing is sent when no bucket changed
+//	event     an anomaly detected for a streamed coin at the streamed
+//	          resolution, as it happens
+//	ack       a request was applied
+//	pong      the answer to a ping
+//	error     a request was rejected, with a "code" and "message"
 func WSHandler(w http.ResponseWriter, r *http.Request) {
     conn, err := upgrader.Upgrade(w, r, nil)
     if err != nil {
@@ -38,188 +63,120 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
     defer conn.Close()
     log.Println("[WS] connection established")
 
-    // --- initial overrides from query params ---
-    var (
-        filterCodes []string
-        useFilter   bool
-        fixedStart  time.Time
-        fixedEnd    time.Time
-        useFixed    bool
-    )
-    if tok := r.URL.Query().Get("tokens"); tok != "" {
-        filterCodes = strings.Split(tok, ",")
-        useFilter = true
-        log.Printf("[WS] initial token filter: %v", filterCodes)
-    }
-    if s := r.URL.Query().Get("start_time"); s != "" {
-        if t, err := time.Parse(time.RFC3339, s); err == nil {
-            fixedStart = t.UTC()
-            useFixed = true
-            log.Printf("[WS] initial start_time override: %s", fixedStart)
-        }
-    }
-    if e := r.URL.Query().Get("end_time"); e != "" {
-        if t, err := time.Parse(time.RFC3339, e); err == nil {
-            fixedEnd = t.UTC()
-            useFixed = true
-            log.Printf("[WS] initial end_time override: %s", fixedEnd)
-        }
-    }
-
-    // channel to trigger immediate refresh
-    overrideCh := make(chan struct{}, 1)
-
-    // --- reader goroutine for JSON control frames ---
-    go func() {
-        for {
-            var msg struct {
-                Tokens    *[]string `json:"tokens"`
-                StartTime *string   `json:"start_time"`
-                EndTime   *string   `json:"end_time"`
-            }
-            if err := conn.ReadJSON(&msg); err != nil {
-                log.Println("[WS] read JSON error:", err)
-                close(overrideCh)
-                return
-            }
-            log.Printf("[WS] got override frame: %+v", msg)
-
-            // tokens logic: nil = no key, empty slice = explicit empty
-            if msg.Tokens != nil {
-                filterCodes = *msg.Tokens
-                useFilter = true
-                log.Printf("[WS] updated token filter: %v", filterCodes)
-            } else {
-                useFilter = false
-                log.Println("[WS] no tokens key → sending ALL coins")
-            }
+    // --- initial subscription from query params ---
+    sub, qerr := subscriptionFromQuery(r.URL.Query())
+    log.Printf("[WS] initial subscription: coins=%v strategy=%s resolution=%s fixed=%v",
+        sub.codes(), sub.Strategy, sub.Resolution.Name, sub.Fixed)
 
-            // start_time override
-            if msg.StartTime != nil {
-                if t, err := time.Parse(time.RFC3339, *msg.StartTime); err == nil {
-                    fixedStart = t.UTC()
-                    useFixed = true
-                    log.Printf("[WS] updated start_time: %s", fixedStart)
-                } else {
-                    log.Printf("[WS] bad start_time %q: %v", *msg.StartTime, err)
-                }
-            }
-            // end_time override
-            if msg.EndTime != nil {
-                if t, err := time.Parse(time.RFC3339, *msg.EndTime); err == nil {
-                    fixedEnd = t.UTC()
-                    useFixed = true
-                    log.Printf("[WS] updated end_time: %s", fixedEnd)
-                } else {
-                    log.Printf("[WS] bad end_time %q: %v", *msg.EndTime, err)
-                }
-            }
+    eventCh, stopEvents := events.Subscribe()
+    defer stopEvents()
 
-            // fire an immediate refresh
-            select {
-            case overrideCh <- struct{}{}:
-            default:
-            }
-        }
-    }()
+    // initial snapshot, then deltas as the hub refreshes (or every minute
+    // for a fixed window), plus a fresh snapshot after each change
+    newWSSession(conn, liveHub, eventCh, sub).serve(qerr)
+}
 
-    // helper: generate every 5-min tick between start and end
-    makeTimeline := func(start, end time.Time) []time.Time {
-        start = start.Truncate(5 * time.Minute)
-        var series []time.Time
-        for t := start; !t.After(end); t = t.Add(5 * time.Minute) {
-            series = append(series, t)
-        }
-        return series
+// makeTimeline generates every tick of step between start and end.
+func makeTimeline(start, end time.Time, step time.Duration) []time.Time {
+    start = start.Truncate(step)
+    var series []time.Time
+    for t := start; !t.After(end); t = t.Add(step) {
+        series = append(series, t)
     }
+    return series
+}
 
-    // core send logic
-    send := func() {
-        // pick window
-        var start, end time.Time
-        if useFixed {
-            start, end = fixedStart, fixedEnd
-            log.Printf("[WS] using fixed window %s → %s", start, end)
-        } else {
-            end = time.Now().UTC()
-            start = end.Add(-1 * time.Hour)
-            log.Printf("[WS] using default window %s → %s", start, end)
+// bucketPayload lays the rows of feed out as one entry per bucket of the
+// window, with a score (zero if missing), stats and sources for every
+// coin sub streams, plus topics and indicators when sub asks for them.
+func bucketPayload(sub wsSubscription, feed *feedData) []map[string]interface{} {
+    start, end := feed.Start, feed.End
+    resolution := sub.Resolution
+
+    // bucket by minute → map[timestamp][code] = score (and stats)
+    buckets := make(map[time.Time]map[string]float64)
+    bucketStats := make(map[time.Time]map[string]model.BucketStats)
+    bucketTopics := make(map[time.Time]map[string]map[string]float64)
+    bucketSources := make(map[time.Time]map[string]map[string]float64)
+    for _, a := range feed.Rows {
+        if a.WindowStart.Before(start) {
+            continue // lookback for indicators only
         }
-
-        // fetch aggregated rows
-        aggs, err := db.FetchAggregatedSentimentsBetween(start, end)
-        if err != nil {
-            log.Printf("[WS] fetch error: %v", err)
-            return
+        ts := a.WindowStart.UTC().Truncate(time.Minute)
+        if buckets[ts] == nil {
+            buckets[ts] = make(map[string]float64)
+            bucketStats[ts] = make(map[string]model.BucketStats)
+            bucketTopics[ts] = make(map[string]map[string]float64)
+            bucketSources[ts] = make(map[string]map[string]float64)
         }
-        log.Printf("[WS] fetched %d rows", len(aggs))
-
-        // bucket by minute → map[timestamp][code] = score
-        buckets := make(map[time.Time]map[string]float64)
-        for _, a := range aggs {
-            ts := a.WindowStart.UTC().Truncate(time.Minute)
-            if buckets[ts] == nil {
-                buckets[ts] = make(map[string]float64)
-            }
-            code := strconv.Itoa(a.CurrencyID)
-            if c, ok := currencyCodeMap[a.CurrencyID]; ok {
-                code = c
-            }
-            buckets[ts][code] = a.SentimentScore
+        code := strconv.Itoa(a.CurrencyID)
+        if c, ok := coins.ByID(a.CurrencyID); ok {
+            code = c.Code
         }
+        buckets[ts][code] = a.SentimentScore
+        bucketStats[ts][code] = a.Stats
+        bucketTopics[ts][code] = a.Topics
+        bucketSources[ts][code] = a.Sources
+    }
 
-        // build full timeline
-        timeline := makeTimeline(start, end)
-
-        // determine which codes to include
-        var codes []string
-        if useFilter {
-            codes = filterCodes
-        } else {
-            for _, c := range coinsList {
-                codes = append(codes, c.Code)
+    // build full timeline
+    timeline := makeTimeline(start, end, resolution.Step)
+    codes := sub.codes()
+
+    // derived series over the same windows, warmed up on earlier rows
+    var indValues map[time.Time]map[string]map[string]*float64
+    if len(sub.Indicators) > 0 && len(timeline) > 0 {
+        ids := make(map[int]string, len(codes))
+        for _, code := range codes {
+            if c, ok := coinByCode(code); ok {
+                ids[c.ID] = c.Code
             }
         }
-        sort.Strings(codes)
+        indEnd := timeline[len(timeline)-1].Add(resolution.Step)
+        _, indValues = indicatorValues(feed.Rows, resolution, ids, sub.Indicators, start, indEnd)
+    }
 
-        // assemble payload
-        resp := make([]map[string]interface{}, 0, len(timeline))
-        for _, ts := range timeline {
-            data := make(map[string]float64, len(codes))
-            bucket := buckets[ts]
+    // assemble payload
+    resp := make([]map[string]interface{}, 0, len(timeline))
+    for _, ts := range timeline {
+        data := make(map[string]float64, len(codes))
+        stats := make(map[string]model.BucketStats, len(codes))
+        sources := make(map[string]map[string]float64, len(codes))
+        bucket := buckets[ts]
+        for _, code := range codes {
+            data[code] = bucket[code] // zero if missing
+            stats[code] = bucketStats[ts][code]
+            sources[code] = bucketSources[ts][code]
+        }
+        entry := map[string]interface{}{
+            "time":       ts.Format(bucketTimeFormat),
+            "strategy":   sub.Strategy,
+            "resolution": resolution.Name,
+            "coins":      data,
+            "stats":      stats,
+            "sources":    sources,
+        }
+        if sub.Topics != nil {
+            topics := make(map[string]map[string]float64, len(codes))
             for _, code := range codes {
-                data[code] = bucket[code] // zero if missing
+                scores := map[string]float64{}
+                for qid, score := range bucketTopics[ts][code] {
+                    if _, ok := sub.Topics[qid]; ok {
+                        scores[qid] = score
+                    }
+                }
+                topics[code] = scores
             }
-            log.Printf("[WS] bucket %s → %+v", ts.Format(time.RFC3339), data)
-            resp = append(resp, map[string]interface{}{
-                "time":  ts.Format("2006-01-02T15:04Z"),
-                "coins": data,
-            })
+            entry["topics"] = topics
         }
-
-        // send JSON
-        if err := conn.WriteJSON(resp); err != nil {
-            log.Println("[WS] write error:", err)
-        } else {
-            log.Printf("[WS] sent %d buckets", len(resp))
-        }
-    }
-
-    // initial send, then every minute, plus immediate on override
-    send()
-    ticker := time.NewTicker(1 * time.Minute)
-    defer ticker.Stop()
-
-    for {
-        select {
-        case <-ticker.C:
-            send()
-        case _, ok := <-overrideCh:
-            if !ok {
-                return
+        if len(sub.Indicators) > 0 {
+            inds := make(map[string]map[string]*float64, len(codes))
+            for _, code := range codes {
+                inds[code] = indValues[ts][code]
             }
-            log.Println("[WS] override fired — immediate send")
-            send()
+            entry["indicators"] = inds
         }
+        resp = append(resp, entry)
     }
-}
\ No newline at end of file
+    return resp
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/aggregate"
+	"github.com/cosmic-hash/CryptoPulse/pkg/coins"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// wsProtocolVersion is the /ws control protocol version this server
+// speaks. Requests that leave "v" out are read as this version.
+const wsProtocolVersion = 1
+
+// maxWindowBuckets bounds the buckets a fixed window covers, lookback
+// included, since every poll sends all of them.
+const maxWindowBuckets = maxIndicatorBuckets
+
+// Request types a /ws client may send.
+const (
+	wsSubscribe   = "subscribe"   // start streaming; sets coins and any option
+	wsUnsubscribe = "unsubscribe" // drop the listed coins, or stop streaming
+	wsSetWindow   = "set_window"  // pin start_time/end_time, or clear both for live
+	wsSetOptions  = "set_options" // change strategy, resolution, topics, indicators
+	wsPing        = "ping"        // answered with pong
+)
+
+// Error codes sent in error frames.
+const (
+	wsErrBadFrame           = "bad_frame"
+	wsErrUnsupportedVersion = "unsupported_version"
+	wsErrUnknownType        = "unknown_type"
+	wsErrUnknownCoin        = "unknown_coin"
+	wsErrBadParam           = "bad_param"
+)
+
+// wsRequest is one control frame from a /ws client. ID is echoed in the
+// ack or error frame that answers it.
+type wsRequest struct {
+	V          int       `json:"v"`
+	Type       string    `json:"type"`
+	ID         string    `json:"id"`
+	Tokens     *[]string `json:"tokens"`
+	Strategy   *string   `json:"strategy"`
+	Resolution *string   `json:"resolution"`
+	Topics     *[]string `json:"topics"`
+	Indicators *[]string `json:"indicators"`
+	StartTime  *string   `json:"start_time"`
+	EndTime    *string   `json:"end_time"`
+}
+
+// wsError is a rejected request, sent back as an error frame.
+type wsError struct {
+	Code    string
+	Message string
+}
+
+func (e *wsError) Error() string { return e.Code + ": " + e.Message }
+
+func wsErrorf(code, format string, args ...interface{}) *wsError {
+	return &wsError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// wsSubscription is what one connection streams.
+type wsSubscription struct {
+	// Active is false after an unsubscribe without tokens.
+	Active bool
+	// Codes lists the streamed coins; nil means every enabled coin.
+	Codes      []string
+	Strategy   string
+	Resolution model.Resolution
+	// Topics maps question ID → text; nil streams no topic series.
+	Topics map[string]string
+	// Indicators are the derived series; nil streams none.
+	Indicators []model.Indicator
+	// Fixed pins the window to [Start, End]; otherwise it rolls.
+	Fixed      bool
+	Start, End time.Time
+}
+
+// defaultSubscription streams every enabled coin over the rolling window
+// with the default strategy and resolution.
+func defaultSubscription() wsSubscription {
+	res, _ := model.LookupResolution("")
+	return wsSubscription{Active: true, Strategy: model.DefaultStrategy, Resolution: res}
+}
+
+// codes returns the streamed coin codes, sorted.
+func (s wsSubscription) codes() []string {
+	var out []string
+	if s.Codes != nil {
+		out = append(out, s.Codes...)
+	} else {
+		for _, c := range coins.Enabled() {
+			out = append(out, c.Code)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// streams reports whether s includes the coin with the given code.
+func (s wsSubscription) streams(code string) bool {
+	for _, c := range s.codes() {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// feedKey is the live feed s reads from.
+func (s wsSubscription) feedKey() feedKey {
+	return feedKey{Strategy: s.Strategy, Resolution: s.Resolution.Name}
+}
+
+// subscriptionFromQuery applies the query parameters a /ws URL may carry
+// (tokens, strategy, resolution, topics, indicators, start_time,
+// end_time) to the default subscription.
+func subscriptionFromQuery(q url.Values) (wsSubscription, *wsError) {
+	req := wsRequest{Type: wsSubscribe}
+	opt := func(name string) *string {
+		if v := q.Get(name); v != "" {
+			return &v
+		}
+		return nil
+	}
+	list := func(name string) *[]string {
+		if v := q.Get(name); v != "" {
+			l := strings.Split(v, ",")
+			return &l
+		}
+		return nil
+	}
+	req.Tokens = list("tokens")
+	req.Strategy = opt("strategy")
+	req.Resolution = opt("resolution")
+	req.Topics = list("topics")
+	req.Indicators = list("indicators")
+	req.StartTime = opt("start_time")
+	req.EndTime = opt("end_time")
+	s, _, err := defaultSubscription().apply(req)
+	return s, err
+}
+
+// apply returns the subscription req leads to and whether the client
+// needs a fresh snapshot. A rejected request leaves s as it was.
+func (s wsSubscription) apply(req wsRequest) (wsSubscription, bool, *wsError) {
+	if req.V != 0 && req.V != wsProtocolVersion {
+		return s, false, wsErrorf(wsErrUnsupportedVersion,
+			"protocol version %d not supported (want %d)", req.V, wsProtocolVersion)
+	}
+	next := s
+	switch req.Type {
+	case wsSubscribe:
+		next.Active = true
+		if req.Tokens != nil {
+			codes, err := knownCodes(*req.Tokens)
+			if err != nil {
+				return s, false, err
+			}
+			if len(codes) == 0 {
+				codes = nil // an empty list means every coin
+			}
+			next.Codes = codes
+		}
+		if err := next.setOptions(req); err != nil {
+			return s, false, err
+		}
+		if err := next.setWindow(req); err != nil {
+			return s, false, err
+		}
+	case wsUnsubscribe:
+		if req.Tokens == nil || len(*req.Tokens) == 0 {
+			next.Active = false
+			return next, false, nil
+		}
+		drop, err := knownCodes(*req.Tokens)
+		if err != nil {
+			return s, false, err
+		}
+		keep := []string{}
+		for _, code := range s.codes() {
+			if !containsString(drop, code) {
+				keep = append(keep, code)
+			}
+		}
+		next.Codes = keep
+	case wsSetWindow:
+		if err := next.setWindow(req); err != nil {
+			return s, false, err
+		}
+	case wsSetOptions:
+		if err := next.setOptions(req); err != nil {
+			return s, false, err
+		}
+	case wsPing:
+		return s, false, nil
+	case "":
+		return s, false, wsErrorf(wsErrBadFrame, "missing type")
+	default:
+		return s, false, wsErrorf(wsErrUnknownType, "unknown request type %q", req.Type)
+	}
+	if next.Fixed {
+		step := next.Resolution.Step
+		if n := int(next.End.Sub(next.Start.Truncate(step))/step) + maxLookback(next.Indicators); n > maxWindowBuckets {
+			return s, false, wsErrorf(wsErrBadParam, "window covers %d %s buckets, at most %d allowed",
+				n, next.Resolution.Name, maxWindowBuckets)
+		}
+	}
+	return next, next.Active, nil
+}
+
+// setOptions applies the strategy, resolution, topics and indicators
+// fields that req sets.
+func (s *wsSubscription) setOptions(req wsRequest) *wsError {
+	if req.Strategy != nil {
+		agg, err := model.LookupAggregator(*req.Strategy)
+		if err == nil {
+			err = aggregate.CheckScheduled(agg.Name())
+		}
+		if err != nil {
+			return wsErrorf(wsErrBadParam, "%v", err)
+		}
+		s.Strategy = agg.Name()
+	}
+	if req.Resolution != nil {
+		res, err := model.LookupResolution(*req.Resolution)
+		if err != nil {
+			return wsErrorf(wsErrBadParam, "%v", err)
+		}
+		s.Resolution = res
+	}
+	if req.Topics != nil {
+		if len(*req.Topics) == 0 {
+			s.Topics = nil
+		} else {
+			qs, err := parseQuestionFilter(strings.Join(*req.Topics, ","))
+			if err != nil {
+				return wsErrorf(wsErrBadParam, "%v", err)
+			}
+			s.Topics = qs
+		}
+	}
+	if req.Indicators != nil {
+		inds, err := model.ParseIndicators(*req.Indicators)
+		if err != nil {
+			return wsErrorf(wsErrBadParam, "%v", err)
+		}
+		s.Indicators = inds
+	}
+	return nil
+}
+
+// setWindow pins the window when req sets start_time or end_time, and
+// returns to the rolling window when it sets both to "".
+func (s *wsSubscription) setWindow(req wsRequest) *wsError {
+	if req.StartTime == nil && req.EndTime == nil {
+		if req.Type == wsSetWindow {
+			return wsErrorf(wsErrBadParam, "set_window needs start_time and end_time")
+		}
+		return nil
+	}
+	start, end := "", ""
+	if req.StartTime != nil {
+		start = *req.StartTime
+	}
+	if req.EndTime != nil {
+		end = *req.EndTime
+	}
+	if start == "" && end == "" {
+		s.Fixed, s.Start, s.End = false, time.Time{}, time.Time{}
+		return nil
+	}
+	if start == "" || end == "" {
+		return wsErrorf(wsErrBadParam, "start_time and end_time must be set together")
+	}
+	st, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		return wsErrorf(wsErrBadParam, "bad start_time %q", start)
+	}
+	et, err := time.Parse(time.RFC3339, end)
+	if err != nil {
+		return wsErrorf(wsErrBadParam, "bad end_time %q", end)
+	}
+	if !st.Before(et) {
+		return wsErrorf(wsErrBadParam, "start_time must be before end_time")
+	}
+	s.Fixed, s.Start, s.End = true, st.UTC(), et.UTC()
+	return nil
+}
+
+// knownCodes trims codes and rejects every one the registry does not know.
+func knownCodes(codes []string) ([]string, *wsError) {
+	out := make([]string, 0, len(codes))
+	var unknown []string
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		if _, ok := coinByCode(code); !ok {
+			unknown = append(unknown, code)
+			continue
+		}
+		if !containsString(out, code) {
+			out = append(out, code)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, wsErrorf(wsErrUnknownCoin, "unknown coins: %s", strings.Join(unknown, ", "))
+	}
+	return out, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// fixedWindowPoll is how often a session refetches a fixed window.
+const fixedWindowPoll = time.Minute
+
+// feedSource is where a session gets its buckets: shared live feeds for
+// the rolling window, direct fetches for a fixed one. *hub is the one
+// used outside tests.
+type feedSource interface {
+	subscribe(key feedKey, lookback int) (updates <-chan *feedData, cancel func())
+	current(key feedKey, lookback int) (*feedData, error)
+	window(key feedKey, res model.Resolution, start, end time.Time, lookback int) (*feedData, error)
+}
+
+// wsConn is the part of *websocket.Conn a session uses.
+type wsConn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+}
+
+// wsIncoming is one decoded control frame, or why it could not be decoded.
+type wsIncoming struct {
+	req wsRequest
+	err *wsError
+}
+
+// wsSession is the state of one /ws connection. It runs three goroutines
+// that share nothing but channels:
+//
+//   - the reader decodes control frames and hands them to serve;
+//   - serve owns the subscription, the live feed and what the client has
+//     been sent, and builds every frame;
+//   - the writer is the only one to write to the connection, and numbers
+//     the frames in the order it writes them.
+type wsSession struct {
+	conn   wsConn
+	feeds  feedSource
+	events <-chan db.SentimentEvent
+	poll   time.Duration
+
+	requests chan wsIncoming
+	frames   chan map[string]interface{}
+	done     chan struct{}
+	once     sync.Once
+	writer   sync.WaitGroup
+
+	// owned by serve
+	sub         wsSubscription
+	sent        map[string][]byte // last JSON sent per bucket time
+	liveCh      <-chan *feedData
+	unsubscribe func()
+}
+
+func newWSSession(conn wsConn, feeds feedSource, events <-chan db.SentimentEvent, sub wsSubscription) *wsSession {
+	return &wsSession{
+		conn:        conn,
+		feeds:       feeds,
+		events:      events,
+		poll:        fixedWindowPoll,
+		requests:    make(chan wsIncoming),
+		frames:      make(chan map[string]interface{}, 16),
+		done:        make(chan struct{}),
+		sub:         sub,
+		sent:        map[string][]byte{},
+		unsubscribe: func() {},
+	}
+}
+
+// close ends the session. It is safe to call from any goroutine, more
+// than once.
+func (s *wsSession) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// serve sends the initial snapshot (after reporting initErr, if the
+// initial subscription was rejected), then streams until the client goes
+// away or a write fails. The caller closes the connection afterwards,
+// which also stops the reader.
+func (s *wsSession) serve(initErr *wsError) {
+	s.writer.Add(1)
+	go s.write()
+	go s.read()
+	defer s.writer.Wait()
+	defer s.close()
+	defer func() { s.unsubscribe() }()
+
+	if initErr != nil {
+		s.reject("", initErr)
+	}
+	s.resubscribe()
+	s.sendSnapshot()
+
+	ticker := time.NewTicker(s.poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case feed := <-s.liveCh:
+			s.send(false, feed)
+		case <-ticker.C:
+			if !s.sub.Active || !s.sub.Fixed {
+				continue
+			}
+			if feed, err := s.load(); err == nil {
+				s.send(false, feed)
+			} else {
+				log.Printf("[WS] fetch error: %v", err)
+			}
+		case e := <-s.events:
+			s.sendEvent(e)
+		case in := <-s.requests:
+			s.handle(in)
+		}
+	}
+}
+
+// read decodes control frames until the connection fails.
+func (s *wsSession) read() {
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			log.Println("[WS] read error:", err)
+			s.close()
+			return
+		}
+		var in wsIncoming
+		if err := json.Unmarshal(data, &in.req); err != nil {
+			in.err = wsErrorf(wsErrBadFrame, "invalid JSON: %v", err)
+		}
+		select {
+		case s.requests <- in:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// write stamps each queued frame with the protocol version and the next
+// sequence number and writes it, until the session ends or a write fails.
+// Queued frames are never modified.
+func (s *wsSession) write() {
+	defer s.writer.Done()
+	var seq uint64
+	for {
+		select {
+		case <-s.done:
+			return
+		case frame := <-s.frames:
+			// stamp a copy: the frame may still be read by serve
+			seq++
+			out := make(map[string]interface{}, len(frame)+2)
+			for k, v := range frame {
+				out[k] = v
+			}
+			out["v"] = wsProtocolVersion
+			out["seq"] = seq
+			data, err := json.Marshal(out)
+			if err == nil {
+				err = s.conn.WriteMessage(websocket.TextMessage, data)
+			}
+			if err != nil {
+				log.Println("[WS] write error:", err)
+				s.close()
+				return
+			}
+		}
+	}
+}
+
+// emit queues frame for the writer. It reports false once the session
+// has ended.
+func (s *wsSession) emit(frame map[string]interface{}) bool {
+	select {
+	case s.frames <- frame:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// reject answers request id with an error frame.
+func (s *wsSession) reject(id string, e *wsError) {
+	log.Printf("[WS] rejected request %q: %v", id, e)
+	s.emit(map[string]interface{}{"type": "error", "id": id, "code": e.Code, "message": e.Message})
+}
+
+// handle applies one control request and answers it.
+func (s *wsSession) handle(in wsIncoming) {
+	req := in.req
+	if in.err != nil {
+		s.reject(req.ID, in.err)
+		return
+	}
+	next, snapshot, e := s.sub.apply(req)
+	if e != nil {
+		s.reject(req.ID, e)
+		return
+	}
+	if req.Type == wsPing {
+		s.emit(map[string]interface{}{"type": "pong", "id": req.ID})
+		return
+	}
+	s.sub = next
+	if !s.emit(map[string]interface{}{"type": "ack", "id": req.ID, "request": req.Type}) {
+		return
+	}
+	log.Printf("[WS] applied %s %q: coins=%v active=%v", req.Type, req.ID, s.sub.codes(), s.sub.Active)
+	s.resubscribe()
+	s.sent = map[string][]byte{}
+	if snapshot {
+		s.sendSnapshot()
+	}
+}
+
+// resubscribe moves the session to the live feed its subscription reads,
+// or off the live feeds for a fixed window or an inactive subscription.
+func (s *wsSession) resubscribe() {
+	s.unsubscribe()
+	s.liveCh, s.unsubscribe = nil, func() {}
+	if s.sub.Active && !s.sub.Fixed {
+		s.liveCh, s.unsubscribe = s.feeds.subscribe(s.sub.feedKey(), maxLookback(s.sub.Indicators))
+	} else if s.sub.Active {
+		log.Printf("[WS] using fixed window %s → %s", s.sub.Start, s.sub.End)
+	}
+}
+
+// load fetches the window the subscription covers.
+func (s *wsSession) load() (*feedData, error) {
+	return loadWindow(s.feeds, s.sub)
+}
+
+// sendSnapshot (re)loads the window and resends all of it.
+func (s *wsSession) sendSnapshot() {
+	if !s.sub.Active {
+		return
+	}
+	feed, err := s.load()
+	if err != nil {
+		log.Printf("[WS] fetch error: %v", err)
+		return
+	}
+	s.send(true, feed)
+}
+
+// send resends the whole window for a snapshot, and otherwise only the
+// buckets that are new or changed since the last frame.
+func (s *wsSession) send(snapshot bool, feed *feedData) {
+	resp := bucketPayload(s.sub, feed)
+
+	// diff against what the client already has
+	current, changed, err := diffBuckets(s.sent, resp)
+	if err != nil {
+		log.Printf("[WS] encode error: %v", err)
+		return
+	}
+	frame := map[string]interface{}{
+		"type":   "delta",
+		"window": map[string]string{"start": feed.Start.Format(time.RFC3339), "end": feed.End.Format(time.RFC3339)},
+	}
+	buckets := changed
+	if snapshot {
+		frame["type"] = "snapshot"
+		buckets = resp
+	} else if len(changed) == 0 {
+		log.Println("[WS] no changed buckets, nothing sent")
+		return
+	}
+	frame["buckets"] = buckets
+	if s.emit(frame) {
+		s.sent = current
+		log.Printf("[WS] queued %s with %d buckets", frame["type"], len(buckets))
+	}
+}
+
+// sendEvent pushes e if the client streams its coin and resolution.
+func (s *wsSession) sendEvent(e db.SentimentEvent) {
+	entry := newEventEntry(e)
+	if !s.sub.Active || e.Resolution != s.sub.Resolution.Name || !s.sub.streams(entry.Coin) {
+		return
+	}
+	if s.emit(map[string]interface{}{"type": "event", "event": entry}) {
+		log.Printf("[WS] queued %s event for %s", e.Severity, entry.Coin)
+	}
+}
+
+// loadWindow fetches the window sub covers from feeds: the fixed window
+// if it pins one, the shared rolling window otherwise.
+func loadWindow(feeds feedSource, sub wsSubscription) (*feedData, error) {
+	if sub.Fixed {
+		return feeds.window(sub.feedKey(), sub.Resolution, sub.Start.Truncate(sub.Resolution.Step), sub.End, maxLookback(sub.Indicators))
+	}
+	return feeds.current(sub.feedKey(), maxLookback(sub.Indicators))
+}
+
+// diffBuckets encodes every entry of resp, keyed by bucket time, and
+// returns the encodings together with the entries whose encoding differs
+// from the one in sent.
+func diffBuckets(sent map[string][]byte, resp []map[string]interface{}) (map[string][]byte, []map[string]interface{}, error) {
+	current := make(map[string][]byte, len(resp))
+	changed := make([]map[string]interface{}, 0, len(resp))
+	for _, entry := range resp {
+		key := entry["time"].(string)
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		current[key] = data
+		if !bytes.Equal(sent[key], data) {
+			changed = append(changed, entry)
+		}
+	}
+	return current, changed, nil
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/coins"
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+	"github.com/cosmic-hash/CryptoPulse/pkg/model"
+)
+
+// fakeFeeds serves one canned live feed and delivers pushed updates to
+// its subscribers the way the hub does.
+type fakeFeeds struct {
+	mu   sync.Mutex
+	data *feedData
+	subs map[chan *feedData]bool
+}
+
+func newFakeFeeds(data *feedData) *fakeFeeds {
+	return &fakeFeeds{data: data, subs: map[chan *feedData]bool{}}
+}
+
+func (f *fakeFeeds) subscribe(key feedKey, lookback int) (<-chan *feedData, func()) {
+	ch := make(chan *feedData, 1)
+	f.mu.Lock()
+	f.subs[ch] = true
+	f.mu.Unlock()
+	return ch, func() {
+		f.mu.Lock()
+		delete(f.subs, ch)
+		f.mu.Unlock()
+	}
+}
+
+func (f *fakeFeeds) current(key feedKey, lookback int) (*feedData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.data, nil
+}
+
+func (f *fakeFeeds) window(key feedKey, res model.Resolution, start, end time.Time, lookback int) (*feedData, error) {
+	return &feedData{Start: start, End: end, From: start}, nil
+}
+
+func (f *fakeFeeds) push(data *feedData) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data = data
+	for ch := range f.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- data
+	}
+}
+
+var testStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testFeed is a three-bucket 5m window with a BTC and an ETH score in
+// each bucket; btc is BTC's score in the last one.
+func testFeed(btc float64) *feedData {
+	res, _ := model.LookupResolution("5m")
+	data := &feedData{Start: testStart, End: testStart.Add(2 * res.Step), From: testStart}
+	for i := 0; i < 3; i++ {
+		ts := testStart.Add(time.Duration(i) * res.Step)
+		score := 0.1
+		if i == 2 {
+			score = btc
+		}
+		data.Rows = append(data.Rows,
+			db.AggregatedSentiment{CurrencyID: 1, WindowStart: ts, SentimentScore: score},
+			db.AggregatedSentiment{CurrencyID: 2, WindowStart: ts, SentimentScore: -0.2})
+	}
+	return data
+}
+
+// dialSession starts a session on feeds behind a test server and returns
+// the client end, plus a channel closed when the session has ended.
+func dialSession(t *testing.T, feeds feedSource, events <-chan db.SentimentEvent, query string) (*websocket.Conn, <-chan struct{}) {
+	t.Helper()
+	coins.Load([]db.Currency{
+		{ID: 1, Code: "BTC", Enabled: true},
+		{ID: 2, Code: "ETH", Enabled: true},
+	})
+	ended := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(ended)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		sub, qerr := subscriptionFromQuery(r.URL.Query())
+		newWSSession(conn, feeds, events, sub).serve(qerr)
+	}))
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?" + query
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, ended
+}
+
+func readFrame(t *testing.T, c *websocket.Conn) map[string]interface{} {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var frame map[string]interface{}
+	if err := c.ReadJSON(&frame); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	return frame
+}
+
+func expectType(t *testing.T, frame map[string]interface{}, typ string) {
+	t.Helper()
+	if frame["type"] != typ {
+		t.Fatalf("got %v frame, want %s: %v", frame["type"], typ, frame)
+	}
+}
+
+// bucketCoins returns the coin codes in the first bucket of frame.
+func bucketCoins(t *testing.T, frame map[string]interface{}) []string {
+	t.Helper()
+	buckets, _ := frame["buckets"].([]interface{})
+	if len(buckets) == 0 {
+		t.Fatalf("frame has no buckets: %v", frame)
+	}
+	var out []string
+	for code := range buckets[0].(map[string]interface{})["coins"].(map[string]interface{}) {
+		out = append(out, code)
+	}
+	return out
+}
+
+func TestWSSessionSnapshotThenDelta(t *testing.T) {
+	feeds := newFakeFeeds(testFeed(0.1))
+	c, _ := dialSession(t, feeds, nil, "tokens=BTC&resolution=5m")
+
+	snap := readFrame(t, c)
+	expectType(t, snap, "snapshot")
+	if snap["seq"] != 1.0 || snap["v"] != float64(wsProtocolVersion) {
+		t.Fatalf("snapshot seq/v = %v/%v", snap["seq"], snap["v"])
+	}
+	if len(snap["buckets"].([]interface{})) != 3 {
+		t.Fatalf("snapshot has %d buckets, want 3", len(snap["buckets"].([]interface{})))
+	}
+	if got := bucketCoins(t, snap); len(got) != 1 || got[0] != "BTC" {
+		t.Fatalf("snapshot coins = %v, want [BTC]", got)
+	}
+
+	// an unchanged feed sends nothing; a changed bucket sends only it
+	feeds.push(testFeed(0.1))
+	feeds.push(testFeed(0.9))
+	delta := readFrame(t, c)
+	expectType(t, delta, "delta")
+	if delta["seq"] != 2.0 {
+		t.Fatalf("delta seq = %v, want 2", delta["seq"])
+	}
+	if n := len(delta["buckets"].([]interface{})); n != 1 {
+		t.Fatalf("delta has %d buckets, want 1", n)
+	}
+}
+
+func TestWSSessionRejectsBadRequests(t *testing.T) {
+	c, _ := dialSession(t, newFakeFeeds(testFeed(0.1)), nil, "tokens=BTC&resolution=5m")
+	expectType(t, readFrame(t, c), "snapshot")
+
+	for _, tc := range []struct {
+		frame string
+		code  string
+	}{
+		{`{"v":1,"type":"subscribe","id":"a","tokens":["ETH","NOPE"]}`, wsErrUnknownCoin},
+		{`{"v":2,"type":"ping","id":"b"}`, wsErrUnsupportedVersion},
+		{`{"v":1,"type":"resize","id":"c"}`, wsErrUnknownType},
+		{`{"v":1,"type":"set_window","id":"d","start_time":"yesterday","end_time":"today"}`, wsErrBadParam},
+		{`{"v":1,"type":"set_options","id":"e","resolution":"7m"}`, wsErrBadParam},
+		{`{"v":1,"type":"set_window","id":"d","start_time":"2025-01-01T00:00:00Z","end_time":"2026-01-01T00:00:00Z"}`, wsErrBadParam}, // too many buckets
+		{`{"v":1,"type":"set_options","id":"e","strategy":"weighted_median"}`, wsErrBadParam},                                         // not scheduled
+		{`not json`, wsErrBadFrame},
+	} {
+		if err := c.WriteMessage(websocket.TextMessage, []byte(tc.frame)); err != nil {
+			t.Fatal(err)
+		}
+		frame := readFrame(t, c)
+		expectType(t, frame, "error")
+		if frame["code"] != tc.code {
+			t.Errorf("%s: code = %v, want %s", tc.frame, frame["code"], tc.code)
+		}
+	}
+
+	// nothing changed: a request without tokens keeps the BTC filter
+	if err := c.WriteJSON(map[string]interface{}{"v": 1, "type": "set_options", "id": "f"}); err != nil {
+		t.Fatal(err)
+	}
+	ack := readFrame(t, c)
+	expectType(t, ack, "ack")
+	if ack["id"] != "f" || ack["request"] != wsSetOptions {
+		t.Fatalf("ack = %v", ack)
+	}
+	snap := readFrame(t, c)
+	expectType(t, snap, "snapshot")
+	if got := bucketCoins(t, snap); len(got) != 1 || got[0] != "BTC" {
+		t.Fatalf("coins after rejected subscribe = %v, want [BTC]", got)
+	}
+}
+
+func TestWSSessionUnsubscribe(t *testing.T) {
+	feeds := newFakeFeeds(testFeed(0.1))
+	events := make(chan db.SentimentEvent, 1)
+	c, _ := dialSession(t, feeds, events, "resolution=5m")
+	expectType(t, readFrame(t, c), "snapshot")
+
+	if err := c.WriteJSON(map[string]interface{}{"type": "unsubscribe", "id": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	expectType(t, readFrame(t, c), "ack")
+
+	// neither feed updates nor events reach an unsubscribed client
+	feeds.push(testFeed(0.9))
+	events <- db.SentimentEvent{CurrencyID: 1, Resolution: "5m", Severity: "major"}
+	if err := c.WriteJSON(map[string]interface{}{"type": "ping", "id": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	pong := readFrame(t, c)
+	expectType(t, pong, "pong")
+	if pong["id"] != "2" {
+		t.Fatalf("pong id = %v", pong["id"])
+	}
+
+	if err := c.WriteJSON(map[string]interface{}{"type": "subscribe", "id": "3", "tokens": []string{"ETH"}}); err != nil {
+		t.Fatal(err)
+	}
+	expectType(t, readFrame(t, c), "ack")
+	snap := readFrame(t, c)
+	expectType(t, snap, "snapshot")
+	if got := bucketCoins(t, snap); len(got) != 1 || got[0] != "ETH" {
+		t.Fatalf("coins after subscribe = %v, want [ETH]", got)
+	}
+}
+
+// TestWSSessionConcurrent drives requests, feed updates and events at a
+// session all at once; run it with -race.
+func TestWSSessionConcurrent(t *testing.T) {
+	feeds := newFakeFeeds(testFeed(0))
+	events := make(chan db.SentimentEvent)
+	c, ended := dialSession(t, feeds, events, "resolution=5m")
+
+	const requests = 50
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		tokens := [][]string{{"BTC"}, {"ETH"}, {}, {"BTC", "ETH"}}
+		for i := 0; i < requests; i++ {
+			req := map[string]interface{}{"v": 1, "type": "subscribe", "id": fmt.Sprint(i), "tokens": tokens[i%len(tokens)]}
+			if i%5 == 0 {
+				req = map[string]interface{}{"v": 1, "type": "ping", "id": fmt.Sprint(i)}
+			}
+			if err := c.WriteJSON(req); err != nil {
+				t.Errorf("write: %v", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				feeds.push(testFeed(float64(i%10) / 10))
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			case events <- db.SentimentEvent{CurrencyID: 1, Resolution: "5m", Severity: "minor"}:
+			case <-ended:
+				return
+			}
+		}
+	}()
+
+	answered := map[string]bool{}
+	var seq float64
+	for len(answered) < requests {
+		frame := readFrame(t, c)
+		seq++
+		if frame["seq"] != seq {
+			t.Fatalf("seq = %v, want %v", frame["seq"], seq)
+		}
+		switch frame["type"] {
+		case "ack", "pong":
+			answered[frame["id"].(string)] = true
+		case "error":
+			t.Fatalf("unexpected error frame: %v", frame)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	c.Close()
+	select {
+	case <-ended:
+	case <-time.After(5 * time.Second):
+		t.Fatal("session did not end after the client left")
+	}
+}
+
+func TestFixedWindowBucketCap(t *testing.T) {
+	coins.Load([]db.Currency{{ID: 1, Code: "BTC", Enabled: true}})
+	start, end := "2026-01-01T00:00:00Z", "2026-01-11T00:00:00Z"
+	hourly := "1h"
+	sub, _, err := defaultSubscription().apply(wsRequest{Type: wsSubscribe, Resolution: &hourly, StartTime: &start, EndTime: &end})
+	if err != nil {
+		t.Fatalf("ten days of 1h buckets rejected: %v", err)
+	}
+	// the window stays, so a finer resolution must fit it too
+	minutely := "1m"
+	if _, _, err := sub.apply(wsRequest{Type: wsSetOptions, Resolution: &minutely}); err == nil || err.Code != wsErrBadParam {
+		t.Fatalf("ten days of 1m buckets: err = %v, want %s", err, wsErrBadParam)
+	}
+	live, empty := "", ""
+	sub.Resolution = model.Resolutions[0]
+	if _, _, err := sub.apply(wsRequest{Type: wsSetWindow, StartTime: &live, EndTime: &empty}); err != nil {
+		t.Fatalf("back to the rolling window at 1m: %v", err)
+	}
+}
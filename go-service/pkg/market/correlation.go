@@ -0,0 +1,155 @@
+package market
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+// minPoints is the fewest paired observations a correlation is reported
+// for; below it the coefficient is left out.
+const minPoints = 3
+
+// Correlation relates a sentiment series to a return series. Pearson and
+// Spearman are nil when there are too few points or a series is flat.
+type Correlation struct {
+	Points   int      `json:"points"`
+	Pearson  *float64 `json:"pearson"`
+	Spearman *float64 `json:"spearman"`
+}
+
+// LagCorrelation is the correlation between sentiment in each window and
+// the return Lag windows later. A positive Lag tests whether sentiment
+// leads price, a negative one whether price leads sentiment.
+type LagCorrelation struct {
+	Lag int `json:"lag"`
+	Correlation
+}
+
+// Returns resamples candles, which must be ordered oldest first, into
+// windows of step and returns the close-to-close return of every window
+// whose previous window also has a candle. A window's close is the close
+// of the last candle opened in it.
+func Returns(candles []db.Candle, step time.Duration) map[time.Time]float64 {
+	closes := map[time.Time]float64{}
+	for _, c := range candles {
+		closes[c.OpenTime.UTC().Truncate(step)] = c.Close
+	}
+	out := make(map[time.Time]float64, len(closes))
+	for t, c := range closes {
+		if prev, ok := closes[t.Add(-step)]; ok && prev > 0 {
+			out[t] = c/prev - 1
+		}
+	}
+	return out
+}
+
+// CrossCorrelate correlates sentiment with returns, both keyed by window
+// start, at every lag from -maxLag to maxLag windows of step.
+func CrossCorrelate(sentiment, returns map[time.Time]float64, step time.Duration, maxLag int) []LagCorrelation {
+	out := make([]LagCorrelation, 0, 2*maxLag+1)
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		out = append(out, LagCorrelation{Lag: lag, Correlation: Correlate(sentiment, returns, step, lag)})
+	}
+	return out
+}
+
+// Correlate pairs sentiment in each window with the return lag windows
+// of step later and computes both coefficients over the pairs.
+func Correlate(sentiment, returns map[time.Time]float64, step time.Duration, lag int) Correlation {
+	times := make([]time.Time, 0, len(sentiment))
+	for t := range sentiment {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	var xs, ys []float64
+	for _, t := range times {
+		if r, ok := returns[t.Add(time.Duration(lag)*step)]; ok {
+			xs = append(xs, sentiment[t])
+			ys = append(ys, r)
+		}
+	}
+	c := Correlation{Points: len(xs)}
+	if len(xs) < minPoints {
+		return c
+	}
+	if v, ok := Pearson(xs, ys); ok {
+		c.Pearson = &v
+	}
+	if v, ok := Spearman(xs, ys); ok {
+		c.Spearman = &v
+	}
+	return c
+}
+
+// Pearson returns the Pearson correlation coefficient of xs and ys, which
+// must have the same length. ok is false when either series is constant.
+func Pearson(xs, ys []float64) (r float64, ok bool) {
+	n := float64(len(xs))
+	if n == 0 || constant(xs) || constant(ys) {
+		// checked up front: the rounding in the means leaves a constant
+		// series a tiny nonzero spread
+		return 0, false
+	}
+	mx, my := 0.0, 0.0
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= n
+	my /= n
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+	return sxy / math.Sqrt(sxx*syy), true
+}
+
+// constant reports whether every value in vs is the same.
+func constant(vs []float64) bool {
+	for _, v := range vs {
+		if v != vs[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// Spearman returns the Spearman rank correlation coefficient of xs and
+// ys: the Pearson coefficient of their ranks, with ties sharing the
+// average rank.
+func Spearman(xs, ys []float64) (float64, bool) {
+	return Pearson(ranks(xs), ranks(ys))
+}
+
+// ranks returns the 1-based rank of each value in vs, giving tied values
+// the mean of the ranks they span.
+func ranks(vs []float64) []float64 {
+	idx := make([]int, len(vs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return vs[idx[a]] < vs[idx[b]] })
+	out := make([]float64, len(vs))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && vs[idx[j+1]] == vs[idx[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			out[idx[k]] = rank
+		}
+		i = j + 1
+	}
+	return out
+}
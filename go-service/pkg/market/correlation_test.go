@@ -0,0 +1,126 @@
+package market
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+var testStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestPearson(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		xs, ys []float64
+		want   float64
+		ok     bool
+	}{
+		{"perfect", []float64{1, 2, 3}, []float64{2, 4, 6}, 1, true},
+		{"inverse", []float64{1, 2, 3}, []float64{0.3, 0.2, 0.1}, -1, true},
+		{"partial", []float64{1, 2, 3, 4, 5}, []float64{2, 1, 4, 3, 5}, 0.8, true},
+		{"constant x", []float64{0.2, 0.2, 0.2}, []float64{1, 2, 3}, 0, false},
+		{"constant y", []float64{1, 2, 3}, []float64{-0.1, -0.1, -0.1}, 0, false},
+		{"empty", nil, nil, 0, false},
+	} {
+		got, ok := Pearson(tc.xs, tc.ys)
+		if ok != tc.ok || (ok && !near(got, tc.want)) {
+			t.Errorf("%s: Pearson = %v, %v; want %v, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestSpearman(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		xs, ys []float64
+		want   float64
+		ok     bool
+	}{
+		// monotonic but far from linear: rank correlation is exact
+		{"monotonic", []float64{1, 2, 3, 4}, []float64{1, 4, 9, 100}, 1, true},
+		{"reversed", []float64{1, 2, 3, 4}, []float64{100, 9, 4, 1}, -1, true},
+		// ranks [1 2.5 2.5 4] against [1 2 3 4]
+		{"ties", []float64{1, 2, 2, 3}, []float64{1, 2, 3, 4}, 4.5 / math.Sqrt(22.5), true},
+		{"constant", []float64{5, 5, 5}, []float64{1, 2, 3}, 0, false},
+	} {
+		got, ok := Spearman(tc.xs, tc.ys)
+		if ok != tc.ok || (ok && !near(got, tc.want)) {
+			t.Errorf("%s: Spearman = %v, %v; want %v, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestRanks(t *testing.T) {
+	for _, tc := range []struct {
+		vs, want []float64
+	}{
+		{[]float64{30, 10, 20}, []float64{3, 1, 2}},
+		{[]float64{10, 20, 20, 30}, []float64{1, 2.5, 2.5, 4}},
+		{[]float64{5, 5, 5}, []float64{2, 2, 2}},
+		{[]float64{-1, 2, -1, 2}, []float64{1.5, 3.5, 1.5, 3.5}},
+	} {
+		got := ranks(tc.vs)
+		for i := range got {
+			if !near(got[i], tc.want[i]) {
+				t.Errorf("ranks(%v) = %v, want %v", tc.vs, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestReturns(t *testing.T) {
+	at := func(m int) time.Time { return testStart.Add(time.Duration(m) * time.Minute) }
+	candles := []db.Candle{
+		{OpenTime: at(0), Close: 100},
+		{OpenTime: at(3), Close: 110}, // the last candle sets the window's close
+		{OpenTime: at(5), Close: 121},
+		{OpenTime: at(9).In(time.FixedZone("CET", 3600)), Close: 99},
+		{OpenTime: at(20), Close: 50}, // no candle in the window before it
+	}
+	got := Returns(candles, 5*time.Minute)
+	want := map[time.Time]float64{at(5): 99.0/110 - 1}
+	if len(got) != len(want) {
+		t.Fatalf("returns = %v, want %v", got, want)
+	}
+	for ts, r := range want {
+		if !near(got[ts], r) {
+			t.Errorf("return at %s = %v, want %v", ts.Format(time.RFC3339), got[ts], r)
+		}
+	}
+}
+
+func TestCorrelateLags(t *testing.T) {
+	step := 5 * time.Minute
+	at := func(i int) time.Time { return testStart.Add(time.Duration(i) * step) }
+	scores := []float64{0.1, -0.2, 0.3, 0, 0.5}
+	sentiment := map[time.Time]float64{}
+	returns := map[time.Time]float64{}
+	for i, s := range scores {
+		sentiment[at(i)] = s
+		returns[at(i+1)] = 2 * s // the price follows sentiment one window later
+	}
+
+	lags := CrossCorrelate(sentiment, returns, step, 2)
+	if len(lags) != 5 || lags[0].Lag != -2 || lags[4].Lag != 2 {
+		t.Fatalf("lags = %+v, want -2 to 2", lags)
+	}
+	lead := lags[3]
+	if lead.Lag != 1 || lead.Points != 5 || lead.Pearson == nil || !near(*lead.Pearson, 1) ||
+		lead.Spearman == nil || !near(*lead.Spearman, 1) {
+		t.Fatalf("lag 1 = %+v, want 5 points correlated 1", lead)
+	}
+	if same := lags[2]; same.Points != 4 || same.Pearson == nil || near(*same.Pearson, 1) {
+		t.Errorf("lag 0 = %+v, want 4 points not perfectly correlated", same)
+	}
+	if back := lags[0]; back.Points != 2 || back.Pearson != nil || back.Spearman != nil {
+		t.Errorf("lag -2 = %+v, want 2 points and no coefficients", back)
+	}
+	if far := Correlate(sentiment, returns, step, 9); far.Points != 0 || far.Pearson != nil {
+		t.Errorf("lag 9 = %+v, want no points", far)
+	}
+}
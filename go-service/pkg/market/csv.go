@@ -0,0 +1,135 @@
+// Package market holds price data for coins and relates it to sentiment.
+package market
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cosmic-hash/CryptoPulse/pkg/db"
+)
+
+// timeLayouts are the textual timestamp formats ParseCandlesCSV accepts,
+// besides unix seconds and milliseconds. Zone-less ones are read as UTC.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// columnNames maps the accepted header names to candle fields.
+var columnNames = map[string]string{
+	"time":      "time",
+	"timestamp": "time",
+	"open_time": "time",
+	"date":      "time",
+	"open":      "open",
+	"high":      "high",
+	"low":       "low",
+	"close":     "close",
+	"volume":    "volume",
+}
+
+// ParseCandlesCSV reads OHLCV candles from CSV with a header row naming
+// the time, open, high, low and close columns, plus an optional volume
+// column. Header names are case-insensitive and columns may come in any
+// order. The candles are returned oldest first; a repeated open time
+// keeps the last row.
+func ParseCandlesCSV(r io.Reader) ([]db.Candle, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		if f, ok := columnNames[strings.ToLower(strings.TrimSpace(h))]; ok {
+			cols[f] = i
+		}
+	}
+	for _, f := range []string{"time", "open", "high", "low", "close"} {
+		if _, ok := cols[f]; !ok {
+			return nil, fmt.Errorf("header has no %s column", f)
+		}
+	}
+
+	byTime := map[time.Time]db.Candle{}
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		c, err := parseCandle(rec, cols)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		byTime[c.OpenTime] = c
+	}
+
+	out := make([]db.Candle, 0, len(byTime))
+	for _, c := range byTime {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].OpenTime.Before(out[j].OpenTime) })
+	return out, nil
+}
+
+func parseCandle(rec []string, cols map[string]int) (db.Candle, error) {
+	var c db.Candle
+	t, err := parseTime(rec[cols["time"]])
+	if err != nil {
+		return c, err
+	}
+	c.OpenTime = t
+	fields := map[string]*float64{
+		"open": &c.Open, "high": &c.High, "low": &c.Low, "close": &c.Close, "volume": &c.Volume,
+	}
+	for name, dst := range fields {
+		i, ok := cols[name]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(rec[i]), 64)
+		if err != nil {
+			return c, fmt.Errorf("bad %s %q", name, rec[i])
+		}
+		*dst = v
+	}
+	switch {
+	case c.Close <= 0:
+		return c, fmt.Errorf("close must be positive")
+	case c.Low > c.High:
+		return c, fmt.Errorf("low above high")
+	case c.Volume < 0:
+		return c, fmt.Errorf("volume must not be negative")
+	}
+	return c, nil
+}
+
+// parseTime reads a timestamp in one of timeLayouts or as unix seconds,
+// or milliseconds when the number is too large to be seconds.
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n > 1e11 {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad time %q", s)
+}
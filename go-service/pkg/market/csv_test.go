@@ -0,0 +1,110 @@
+package market
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCandlesCSV(t *testing.T) {
+	const data = `Timestamp, Open, High, Low, Close, Volume
+1767225900,101,103,100,102,7
+1767225600000,100,102,99,101,5
+2026-01-01 00:10,102,104,101,103,0
+2026-01-01T01:15:00+01:00,103,105,102,104,2
+1767225600,100,102,99,100.5,6
+`
+	candles, err := ParseCandlesCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(m int) time.Time { return testStart.Add(time.Duration(m) * time.Minute) }
+	want := []struct {
+		open  time.Time
+		close float64
+		vol   float64
+	}{
+		{at(0), 100.5, 6}, // unix ms, repeated later in unix s: the last row wins
+		{at(5), 102, 7},   // unix s
+		{at(10), 103, 0},  // zone-less text, read as UTC
+		{at(15), 104, 2},  // RFC 3339 with an offset
+	}
+	if len(candles) != len(want) {
+		t.Fatalf("got %d candles, want %d: %+v", len(candles), len(want), candles)
+	}
+	for i, w := range want {
+		c := candles[i]
+		if !c.OpenTime.Equal(w.open) || c.OpenTime.Location() != time.UTC || c.Close != w.close || c.Volume != w.vol {
+			t.Errorf("candle %d = %+v, want open %s close %v volume %v", i, c, w.open, w.close, w.vol)
+		}
+	}
+}
+
+func TestParseCandlesCSVHeaders(t *testing.T) {
+	for _, header := range []string{
+		"time,open,high,low,close",
+		"DATE,Close,Low,High,Open",
+		"open_time,open,high,low,close,volume,trades",
+	} {
+		row := map[string]string{
+			"time": "2026-01-01", "date": "2026-01-01", "open_time": "2026-01-01",
+			"open": "1", "high": "3", "low": "0.5", "close": "2", "volume": "9", "trades": "40",
+		}
+		var fields []string
+		for _, h := range strings.Split(header, ",") {
+			fields = append(fields, row[strings.ToLower(h)])
+		}
+		data := header + "\n" + strings.Join(fields, ",") + "\n"
+		candles, err := ParseCandlesCSV(strings.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", header, err)
+			continue
+		}
+		if len(candles) != 1 {
+			t.Errorf("%s: got %d candles, want 1", header, len(candles))
+			continue
+		}
+		if c := candles[0]; !c.OpenTime.Equal(testStart) || c.Open != 1 || c.High != 3 || c.Low != 0.5 || c.Close != 2 {
+			t.Errorf("%s: candle = %+v", header, c)
+		}
+	}
+}
+
+func TestParseCandlesCSVRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name, data, err string
+	}{
+		{"no close column", "time,open,high,low\n2026-01-01,1,2,1\n", "no close column"},
+		{"empty", "", "read header"},
+		{"bad time", "time,open,high,low,close\nyesterday,1,2,1,2\n", "line 2"},
+		{"bad number", "time,open,high,low,close\n2026-01-01,1,2,1,2\n2026-01-02,1,x,1,2\n", "line 3: bad high"},
+		{"zero close", "time,open,high,low,close\n2026-01-01,1,2,1,0\n", "close must be positive"},
+		{"low above high", "time,open,high,low,close\n2026-01-01,1,2,3,2\n", "low above high"},
+		{"negative volume", "time,open,high,low,close,volume\n2026-01-01,1,2,1,2,-1\n", "volume must not be negative"},
+		{"short row", "time,open,high,low,close\n2026-01-01,1,2\n", "wrong number of fields"},
+	} {
+		_, err := ParseCandlesCSV(strings.NewReader(tc.data))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: err = %v, want it to mention %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	for in, want := range map[string]time.Time{
+		"1767225600":          testStart,
+		"1767225600000":       testStart,
+		" 1767225660 ":        testStart.Add(time.Minute),
+		"2026-01-01":          testStart,
+		"2026-01-01T00:00:30": testStart.Add(30 * time.Second),
+		"2026-01-01 00:00:30": testStart.Add(30 * time.Second),
+	} {
+		got, err := parseTime(in)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseTime(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseTime("01/01/2026"); err == nil {
+		t.Error("parseTime accepted an unknown layout")
+	}
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Sample is one message score with its relative weight inside its question.
+type Sample struct {
+	Score  float64
+	Weight float64
+}
+
+// Samples wraps plain scores as samples of weight 1.
+func Samples(scores []float64) []Sample {
+	out := make([]Sample, len(scores))
+	for i, s := range scores {
+		out[i] = Sample{Score: s, Weight: 1}
+	}
+	return out
+}
+
+// Aggregator collapses per-question message samples into one sentiment.
+type Aggregator interface {
+	Name() string
+	Aggregate(weights map[string]float64, samples map[string][]Sample) float64
+}
+
+// DefaultStrategy is used when a request does not pick one.
+const DefaultStrategy = "weighted_mean"
+
+// Aggregators holds every built-in strategy keyed by name.
+var Aggregators = map[string]Aggregator{
+	"weighted_mean":   WeightedMean{},
+	"weighted_median": WeightedMedian{},
+	"trimmed_mean":    TrimmedMean{Trim: 0.1},
+	"volume_weighted": VolumeWeightedMean{},
+}
+
+// LookupAggregator returns the strategy registered under name.
+// An empty name selects DefaultStrategy.
+func LookupAggregator(name string) (Aggregator, error) {
+	if name == "" {
+		name = DefaultStrategy
+	}
+	a, ok := Aggregators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q", name)
+	}
+	return a, nil
+}
+
+// WeightedMean is the weighted mean of per-question averages.
+type WeightedMean struct{}
+
+func (WeightedMean) Name() string { return "weighted_mean" }
+
+func (WeightedMean) Aggregate(weights map[string]float64, samples map[string][]Sample) float64 {
+	return CalculateWeightedSentiment(weights, samples)
+}
+
+// WeightedMedian gives every message its share of its question's weight
+// and returns the weighted median over all messages.
+type WeightedMedian struct{}
+
+func (WeightedMedian) Name() string { return "weighted_median" }
+
+func (WeightedMedian) Aggregate(weights map[string]float64, samples map[string][]Sample) float64 {
+	type point struct {
+		score, weight float64
+	}
+	var (
+		points []point
+		total  float64
+	)
+	for q, qs := range samples {
+		w, ok := weights[q]
+		mass := sampleMass(qs)
+		if !ok || mass == 0 {
+			continue
+		}
+		for _, s := range qs {
+			points = append(points, point{s.Score, w * s.Weight / mass})
+		}
+		total += w
+	}
+	if total == 0 {
+		return 0
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].score < points[j].score })
+
+	cum := 0.0
+	for _, p := range points {
+		cum += p.weight
+		if cum >= total/2 {
+			return p.score
+		}
+	}
+	return points[len(points)-1].score
+}
+
+// TrimmedMean drops the lowest and highest Trim fraction of each
+// question's scores before taking the weighted mean.
+type TrimmedMean struct {
+	Trim float64
+}
+
+func (TrimmedMean) Name() string { return "trimmed_mean" }
+
+func (t TrimmedMean) Aggregate(weights map[string]float64, samples map[string][]Sample) float64 {
+	trimmed := make(map[string][]Sample, len(samples))
+	for q, qs := range samples {
+		sorted := append([]Sample(nil), qs...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Score < sorted[j].Score })
+		cut := int(float64(len(sorted)) * t.Trim)
+		if len(sorted)-2*cut <= 0 {
+			trimmed[q] = sorted
+			continue
+		}
+		trimmed[q] = sorted[cut : len(sorted)-cut]
+	}
+	return CalculateWeightedSentiment(weights, trimmed)
+}
+
+// VolumeWeightedMean scales each question's weight by the total weight of
+// its messages, so busy topics pull harder.
+type VolumeWeightedMean struct{}
+
+func (VolumeWeightedMean) Name() string { return "volume_weighted" }
+
+func (VolumeWeightedMean) Aggregate(weights map[string]float64, samples map[string][]Sample) float64 {
+	scaled := make(map[string]float64, len(samples))
+	for q, qs := range samples {
+		if w, ok := weights[q]; ok {
+			scaled[q] = w * sampleMass(qs)
+		}
+	}
+	return CalculateWeightedSentiment(scaled, samples)
+}
+
+// sampleMass is the total weight of samples.
+func sampleMass(samples []Sample) float64 {
+	mass := 0.0
+	for _, s := range samples {
+		mass += s.Weight
+	}
+	return mass
+}
@@ -0,0 +1,242 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxIndicatorPeriod bounds the period of any indicator.
+const maxIndicatorPeriod = 500
+
+// Indicator derives one or more series from a sentiment series.
+type Indicator interface {
+	// Name is the canonical spec, e.g. "ema:12".
+	Name() string
+	// Lookback is how many earlier values the first output needs.
+	Lookback() int
+	// Compute returns, per output series, one value for each value of xs;
+	// NaN marks the values that are still warming up.
+	Compute(xs []float64) map[string][]float64
+}
+
+// indicatorKind builds an indicator from its parameters.
+type indicatorKind struct {
+	defaults []float64
+	build    func(p []float64) Indicator
+}
+
+// Indicators holds every built-in indicator keyed by kind. A spec is the
+// kind optionally followed by its parameters, e.g. "sma", "sma:24" or
+// "bollinger:20:2"; missing parameters take the defaults.
+var Indicators = map[string]indicatorKind{
+	"sma":       {[]float64{12}, func(p []float64) Indicator { return SMA{N: int(p[0])} }},
+	"ema":       {[]float64{12}, func(p []float64) Indicator { return EMA{N: int(p[0])} }},
+	"momentum":  {[]float64{10}, func(p []float64) Indicator { return Momentum{N: int(p[0])} }},
+	"roc":       {[]float64{10}, func(p []float64) Indicator { return RateOfChange{N: int(p[0])} }},
+	"bollinger": {[]float64{20, 2}, func(p []float64) Indicator { return Bollinger{N: int(p[0]), K: p[1]} }},
+	"rsi":       {[]float64{14}, func(p []float64) Indicator { return RSI{N: int(p[0])} }},
+}
+
+// ParseIndicator returns the indicator described by spec.
+func ParseIndicator(spec string) (Indicator, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	kind, ok := Indicators[strings.ToLower(parts[0])]
+	if !ok {
+		return nil, fmt.Errorf("unknown indicator %q", spec)
+	}
+	if len(parts)-1 > len(kind.defaults) {
+		return nil, fmt.Errorf("indicator %q takes at most %d parameters", spec, len(kind.defaults))
+	}
+	p := append([]float64(nil), kind.defaults...)
+	for i, s := range parts[1:] {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("indicator %q: bad parameter %q", spec, s)
+		}
+		p[i] = v
+	}
+	// the first parameter is always the period
+	if p[0] != math.Trunc(p[0]) || p[0] < 1 || p[0] > maxIndicatorPeriod {
+		return nil, fmt.Errorf("indicator %q: period must be a whole number between 1 and %d", spec, maxIndicatorPeriod)
+	}
+	return kind.build(p), nil
+}
+
+// ParseIndicators parses every spec, dropping repeats.
+func ParseIndicators(specs []string) ([]Indicator, error) {
+	var out []Indicator
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		ind, err := ParseIndicator(spec)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[ind.Name()] {
+			seen[ind.Name()] = true
+			out = append(out, ind)
+		}
+	}
+	return out, nil
+}
+
+// nanSeries returns n NaNs.
+func nanSeries(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
+
+// SMA is the simple moving average over N values.
+type SMA struct{ N int }
+
+func (s SMA) Name() string  { return fmt.Sprintf("sma:%d", s.N) }
+func (s SMA) Lookback() int { return s.N - 1 }
+
+func (s SMA) Compute(xs []float64) map[string][]float64 {
+	out := nanSeries(len(xs))
+	sum := 0.0
+	for i, x := range xs {
+		sum += x
+		if i >= s.N {
+			sum -= xs[i-s.N]
+		}
+		if i >= s.N-1 {
+			out[i] = sum / float64(s.N)
+		}
+	}
+	return map[string][]float64{s.Name(): out}
+}
+
+// EMA is the exponential moving average with smoothing 2/(N+1), seeded
+// with the simple average of the first N values.
+type EMA struct{ N int }
+
+func (e EMA) Name() string  { return fmt.Sprintf("ema:%d", e.N) }
+func (e EMA) Lookback() int { return e.N - 1 }
+
+func (e EMA) Compute(xs []float64) map[string][]float64 {
+	out := nanSeries(len(xs))
+	alpha := 2 / float64(e.N+1)
+	sum := 0.0
+	for i, x := range xs {
+		switch {
+		case i < e.N-1:
+			sum += x
+		case i == e.N-1:
+			out[i] = (sum + x) / float64(e.N)
+		default:
+			out[i] = alpha*x + (1-alpha)*out[i-1]
+		}
+	}
+	return map[string][]float64{e.Name(): out}
+}
+
+// Momentum is the change over the last N values.
+type Momentum struct{ N int }
+
+func (m Momentum) Name() string  { return fmt.Sprintf("momentum:%d", m.N) }
+func (m Momentum) Lookback() int { return m.N }
+
+func (m Momentum) Compute(xs []float64) map[string][]float64 {
+	out := nanSeries(len(xs))
+	for i := m.N; i < len(xs); i++ {
+		out[i] = xs[i] - xs[i-m.N]
+	}
+	return map[string][]float64{m.Name(): out}
+}
+
+// RateOfChange is the change over the last N values in percent of the
+// older value's magnitude, since sentiment can be negative. It is NaN
+// where the older value is zero.
+type RateOfChange struct{ N int }
+
+func (r RateOfChange) Name() string  { return fmt.Sprintf("roc:%d", r.N) }
+func (r RateOfChange) Lookback() int { return r.N }
+
+func (r RateOfChange) Compute(xs []float64) map[string][]float64 {
+	out := nanSeries(len(xs))
+	for i := r.N; i < len(xs); i++ {
+		if prev := xs[i-r.N]; prev != 0 {
+			out[i] = (xs[i] - prev) / math.Abs(prev) * 100
+		}
+	}
+	return map[string][]float64{r.Name(): out}
+}
+
+// Bollinger is the N-value simple moving average with bands K population
+// standard deviations above and below it, as the series
+// "<name>:upper", "<name>:middle" and "<name>:lower".
+type Bollinger struct {
+	N int
+	K float64
+}
+
+func (b Bollinger) Name() string {
+	return fmt.Sprintf("bollinger:%d:%s", b.N, strconv.FormatFloat(b.K, 'f', -1, 64))
+}
+func (b Bollinger) Lookback() int { return b.N - 1 }
+
+func (b Bollinger) Compute(xs []float64) map[string][]float64 {
+	upper, middle, lower := nanSeries(len(xs)), nanSeries(len(xs)), nanSeries(len(xs))
+	for i := b.N - 1; i < len(xs); i++ {
+		win := xs[i-b.N+1 : i+1]
+		mean := 0.0
+		for _, x := range win {
+			mean += x
+		}
+		mean /= float64(b.N)
+		v := 0.0
+		for _, x := range win {
+			v += (x - mean) * (x - mean)
+		}
+		sd := math.Sqrt(v / float64(b.N))
+		upper[i], middle[i], lower[i] = mean+b.K*sd, mean, mean-b.K*sd
+	}
+	name := b.Name()
+	return map[string][]float64{name + ":upper": upper, name + ":middle": middle, name + ":lower": lower}
+}
+
+// RSI is an oscillator from 0 to 100 comparing the average rise with the
+// average fall over N changes, with Wilder's smoothing. A flat series
+// reads 50.
+type RSI struct{ N int }
+
+func (r RSI) Name() string  { return fmt.Sprintf("rsi:%d", r.N) }
+func (r RSI) Lookback() int { return r.N }
+
+func (r RSI) Compute(xs []float64) map[string][]float64 {
+	out := nanSeries(len(xs))
+	var gain, loss float64
+	for i := 1; i < len(xs); i++ {
+		d := xs[i] - xs[i-1]
+		up, down := math.Max(d, 0), math.Max(-d, 0)
+		switch {
+		case i < r.N:
+			gain += up
+			loss += down
+			continue
+		case i == r.N:
+			gain = (gain + up) / float64(r.N)
+			loss = (loss + down) / float64(r.N)
+		default:
+			gain = (gain*float64(r.N-1) + up) / float64(r.N)
+			loss = (loss*float64(r.N-1) + down) / float64(r.N)
+		}
+		switch {
+		case gain == 0 && loss == 0:
+			out[i] = 50
+		case loss == 0:
+			out[i] = 100
+		default:
+			out[i] = 100 - 100/(1+gain/loss)
+		}
+	}
+	return map[string][]float64{r.Name(): out}
+}
@@ -0,0 +1,161 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+// nan stands for a value that is still warming up in expected series.
+var nan = math.NaN()
+
+func sameSeries(got, want []float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if math.IsNaN(want[i]) {
+			if !math.IsNaN(got[i]) {
+				return false
+			}
+			continue
+		}
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIndicatorCompute(t *testing.T) {
+	for _, tc := range []struct {
+		ind  Indicator
+		xs   []float64
+		want map[string][]float64
+	}{
+		{SMA{N: 3}, []float64{1, 2, 3, 4, 5},
+			map[string][]float64{"sma:3": {nan, nan, 2, 3, 4}}},
+		{SMA{N: 1}, []float64{0.5, -0.5},
+			map[string][]float64{"sma:1": {0.5, -0.5}}},
+		// seeded with the simple average of the first 3, then alpha 0.5
+		{EMA{N: 3}, []float64{2, 4, 6, 8, 2},
+			map[string][]float64{"ema:3": {nan, nan, 4, 6, 4}}},
+		{EMA{N: 3}, []float64{1, 2},
+			map[string][]float64{"ema:3": {nan, nan}}},
+		{Momentum{N: 2}, []float64{1, 2, 4, 7},
+			map[string][]float64{"momentum:2": {nan, nan, 3, 5}}},
+		// relative to the older value's magnitude; NaN where it is zero
+		{RateOfChange{N: 1}, []float64{0, 0.5, -0.5, -0.25},
+			map[string][]float64{"roc:1": {nan, nan, -200, 50}}},
+		{Bollinger{N: 3, K: 2}, []float64{1, 2, 3, 4},
+			map[string][]float64{
+				"bollinger:3:2:upper":  {nan, nan, 2 + 2*math.Sqrt(2.0/3), 3 + 2*math.Sqrt(2.0/3)},
+				"bollinger:3:2:middle": {nan, nan, 2, 3},
+				"bollinger:3:2:lower":  {nan, nan, 2 - 2*math.Sqrt(2.0/3), 3 - 2*math.Sqrt(2.0/3)},
+			}},
+		{Bollinger{N: 2, K: 1.5}, []float64{0.4, 0.4, 0.4},
+			map[string][]float64{
+				"bollinger:2:1.5:upper":  {nan, 0.4, 0.4},
+				"bollinger:2:1.5:middle": {nan, 0.4, 0.4},
+				"bollinger:2:1.5:lower":  {nan, 0.4, 0.4},
+			}},
+		// warm-up averages the first 3 changes, then Wilder's smoothing
+		{RSI{N: 3}, []float64{1, 2, 3, 2, 3},
+			map[string][]float64{"rsi:3": {nan, nan, nan, 100 - 100/3.0, 100 - 100/4.5}}},
+		{RSI{N: 2}, []float64{0.3, 0.3, 0.3, 0.3},
+			map[string][]float64{"rsi:2": {nan, nan, 50, 50}}},
+		{RSI{N: 2}, []float64{0.1, 0.2, 0.3, 0.4},
+			map[string][]float64{"rsi:2": {nan, nan, 100, 100}}},
+		{RSI{N: 2}, []float64{0.4, 0.3, 0.2, 0.1},
+			map[string][]float64{"rsi:2": {nan, nan, 0, 0}}},
+	} {
+		got := tc.ind.Compute(tc.xs)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: series = %v, want %v", tc.ind.Name(), got, tc.want)
+			continue
+		}
+		for name, want := range tc.want {
+			if !sameSeries(got[name], want) {
+				t.Errorf("%s: %s = %v, want %v", tc.ind.Name(), name, got[name], want)
+			}
+		}
+	}
+}
+
+func TestIndicatorLookback(t *testing.T) {
+	for _, tc := range []struct {
+		ind  Indicator
+		want int
+	}{
+		{SMA{N: 12}, 11},
+		{EMA{N: 12}, 11},
+		{Momentum{N: 10}, 10},
+		{RateOfChange{N: 10}, 10},
+		{Bollinger{N: 20, K: 2}, 19},
+		{RSI{N: 14}, 14},
+	} {
+		if got := tc.ind.Lookback(); got != tc.want {
+			t.Errorf("%s: lookback = %d, want %d", tc.ind.Name(), got, tc.want)
+		}
+		// the first value after the lookback is the first one defined
+		xs := make([]float64, tc.want+1)
+		for i := range xs {
+			xs[i] = float64(1 + i%3)
+		}
+		for name, out := range tc.ind.Compute(xs) {
+			if math.IsNaN(out[tc.want]) || (tc.want > 0 && !math.IsNaN(out[tc.want-1])) {
+				t.Errorf("%s: %s warms up at the wrong value: %v", tc.ind.Name(), name, out)
+			}
+		}
+	}
+}
+
+func TestParseIndicator(t *testing.T) {
+	for spec, want := range map[string]string{
+		"sma":              "sma:12",
+		"EMA:5":            "ema:5",
+		" rsi:14 ":         "rsi:14",
+		"roc:1":            "roc:1",
+		"momentum:500":     "momentum:500",
+		"bollinger":        "bollinger:20:2",
+		"bollinger:10":     "bollinger:10:2",
+		"bollinger:20:2.5": "bollinger:20:2.5",
+	} {
+		ind, err := ParseIndicator(spec)
+		if err != nil {
+			t.Errorf("ParseIndicator(%q): %v", spec, err)
+			continue
+		}
+		if ind.Name() != want {
+			t.Errorf("ParseIndicator(%q) = %s, want %s", spec, ind.Name(), want)
+		}
+	}
+
+	for _, spec := range []string{
+		"macd",           // unknown kind
+		"sma:0",          // period below 1
+		"sma:-3",         // negative
+		"sma:1.5",        // not a whole number
+		"sma:501",        // above maxIndicatorPeriod
+		"sma:x",          // not a number
+		"sma:",           // empty parameter
+		"sma:3:4",        // too many parameters
+		"bollinger:20:0", // zero band width
+	} {
+		if ind, err := ParseIndicator(spec); err == nil {
+			t.Errorf("ParseIndicator(%q) = %s, want an error", spec, ind.Name())
+		}
+	}
+}
+
+func TestParseIndicators(t *testing.T) {
+	inds, err := ParseIndicators([]string{"sma", "", "sma:12", "ema:3", " "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inds) != 2 || inds[0].Name() != "sma:12" || inds[1].Name() != "ema:3" {
+		t.Fatalf("indicators = %v, want [sma:12 ema:3]", inds)
+	}
+	if _, err := ParseIndicators([]string{"sma", "rsi:0"}); err == nil {
+		t.Fatal("a bad spec was accepted")
+	}
+}
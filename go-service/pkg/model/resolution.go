@@ -0,0 +1,50 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Resolution is one supported bucket width.
+type Resolution struct {
+	Name string
+	Step time.Duration
+}
+
+// DefaultResolution is used when a request does not pick one.
+const DefaultResolution = "5m"
+
+// Resolutions lists every supported bucket width, finest first.
+// The first entry is computed from raw messages; every other one is
+// rolled up from the entry before it.
+var Resolutions = []Resolution{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+}
+
+// LookupResolution returns the resolution registered under name.
+// An empty name selects DefaultResolution.
+func LookupResolution(name string) (Resolution, error) {
+	if name == "" {
+		name = DefaultResolution
+	}
+	for _, r := range Resolutions {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return Resolution{}, fmt.Errorf("unknown resolution %q", name)
+}
+
+// Finer returns the resolution r is rolled up from, or false for the base.
+func (r Resolution) Finer() (Resolution, bool) {
+	for i, cand := range Resolutions {
+		if cand.Name == r.Name && i > 0 {
+			return Resolutions[i-1], true
+		}
+	}
+	return Resolution{}, false
+}
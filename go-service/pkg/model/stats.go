@@ -0,0 +1,91 @@
+package model
+
+import "math"
+
+// BucketStats describes the message volume and dispersion behind one
+// coin's score in one bucket. A zero Count means the score was carried
+// forward from an earlier bucket.
+type BucketStats struct {
+	Count          int            `json:"count"`
+	Mean           float64        `json:"mean"`
+	StdDev         float64        `json:"stddev"`
+	Min            float64        `json:"min"`
+	Max            float64        `json:"max"`
+	QuestionCounts map[string]int `json:"question_counts,omitempty"`
+	// Dropped counts the messages each filter rule removed, by rule name.
+	Dropped map[string]int `json:"dropped,omitempty"`
+	// SourceCounts counts the scored messages per source.
+	SourceCounts map[string]int `json:"source_counts,omitempty"`
+}
+
+// NewBucketStats summarises raw message scores keyed by question ID.
+func NewBucketStats(scores map[string][]float64) BucketStats {
+	st := BucketStats{QuestionCounts: make(map[string]int, len(scores))}
+	sum, sumSq := 0.0, 0.0
+	for q, qs := range scores {
+		if len(qs) == 0 {
+			continue
+		}
+		st.QuestionCounts[q] += len(qs)
+		for _, s := range qs {
+			if st.Count == 0 || s < st.Min {
+				st.Min = s
+			}
+			if st.Count == 0 || s > st.Max {
+				st.Max = s
+			}
+			st.Count++
+			sum += s
+			sumSq += s * s
+		}
+	}
+	if st.Count == 0 {
+		return st
+	}
+	n := float64(st.Count)
+	st.Mean = sum / n
+	st.StdDev = math.Sqrt(math.Max(sumSq/n-st.Mean*st.Mean, 0))
+	return st
+}
+
+// MergeBucketStats combines the stats of finer buckets into the stats of
+// the coarser bucket that contains them. Empty parts are ignored.
+func MergeBucketStats(parts []BucketStats) BucketStats {
+	out := BucketStats{
+		QuestionCounts: map[string]int{},
+		Dropped:        map[string]int{},
+		SourceCounts:   map[string]int{},
+	}
+	sum, sumSq := 0.0, 0.0
+	for _, p := range parts {
+		for rule, c := range p.Dropped {
+			out.Dropped[rule] += c
+		}
+		if p.Count == 0 {
+			continue
+		}
+		if out.Count == 0 || p.Min < out.Min {
+			out.Min = p.Min
+		}
+		if out.Count == 0 || p.Max > out.Max {
+			out.Max = p.Max
+		}
+		n := float64(p.Count)
+		out.Count += p.Count
+		sum += n * p.Mean
+		sumSq += n * (p.StdDev*p.StdDev + p.Mean*p.Mean)
+		for q, c := range p.QuestionCounts {
+			out.QuestionCounts[q] += c
+		}
+		for src, c := range p.SourceCounts {
+			out.SourceCounts[src] += c
+		}
+	}
+	if out.Count == 0 {
+		return out
+	}
+	n := float64(out.Count)
+	out.Mean = sum / n
+	out.StdDev = math.Sqrt(math.Max(sumSq/n-out.Mean*out.Mean, 0))
+	return out
+}
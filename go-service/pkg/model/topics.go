@@ -0,0 +1,39 @@
+package model
+
+// QuestionAverages returns the mean score per question ID.
+func QuestionAverages(scores map[string][]float64) map[string]float64 {
+	out := make(map[string]float64, len(scores))
+	for q, qs := range scores {
+		if len(qs) == 0 {
+			continue
+		}
+		sum := 0.0
+		for _, s := range qs {
+			sum += s
+		}
+		out[q] = sum / float64(len(qs))
+	}
+	return out
+}
+
+// MergeKeyedAverages combines keyed averages (per question, per source) of
+// finer buckets into those of the coarser bucket, weighting each part by
+// its per-key message count. avgs[i] and counts[i] describe the same part.
+func MergeKeyedAverages(avgs []map[string]float64, counts []map[string]int) map[string]float64 {
+	sums := map[string]float64{}
+	ns := map[string]int{}
+	for i, part := range avgs {
+		for q, avg := range part {
+			n := counts[i][q]
+			sums[q] += avg * float64(n)
+			ns[q] += n
+		}
+	}
+	out := make(map[string]float64, len(sums))
+	for q, sum := range sums {
+		if ns[q] > 0 {
+			out[q] = sum / float64(ns[q])
+		}
+	}
+	return out
+}
@@ -10,18 +10,6 @@ type SentimentResponse struct {
 	FinalSentiment float64 `json:"finalSentiment"`
 }
 
-// DefaultWeights are your per-question weights.
-var DefaultWeights = map[string]float64{
-	"New Features or Use Cases of \"coin_name\"":                                  0.15,
-	"Founders or Leadership of \"coin_name\"":                                     0.10,
-	"Security Concerns or Hacks related to \"coin_name\"":                         0.20,
-	"Market Trends and Price Predictions of \"coin_name\"":                        0.20,
-	"Regulatory Updates and Government Policies affecting \"coin_name\"":          0.10,
-	"Community Sentiment and Adoption for \"coin_name\"":                          0.10,
-	"Partnerships and Integrations involving \"coin_name\"":                       0.10,
-	"Mining and Staking Discussions around \"coin_name\"":                         0.05,
-}
-
 // DefaultMessageScores is used by the WebSocket fallback.
 var DefaultMessageScores = map[string][]float64{
 	"1": {0.1, 0.2, 0.15, 0.1, 0.3, 0.05, 0.2, 0.15, 0.1, 0.2},
@@ -39,11 +27,24 @@ var DefaultMessageScores = map[string][]float64{
 func CalculateFinalSentiment(
     weights map[string]float64,
     messageScores map[string][]float64,
+) float64 {
+    samples := make(map[string][]Sample, len(messageScores))
+    for q, scores := range messageScores {
+        samples[q] = Samples(scores)
+    }
+    return CalculateWeightedSentiment(weights, samples)
+}
+
+// CalculateWeightedSentiment is CalculateFinalSentiment for weighted
+// samples: each question's average is weighted by its samples' weights.
+func CalculateWeightedSentiment(
+    weights map[string]float64,
+    samples map[string][]Sample,
 ) float64 {
     // 1) Figure out the sum of weights for questions we do have
     totalWeight := 0.0
-    for q, scores := range messageScores {
-        if len(scores) == 0 {
+    for q, qs := range samples {
+        if sampleMass(qs) == 0 {
             continue
         }
         if w, ok := weights[q]; ok {
@@ -57,8 +58,9 @@ func CalculateFinalSentiment(
 
     // 2) Build the weighted average across only those questions
     final := 0.0
-    for q, scores := range messageScores {
-        if len(scores) == 0 {
+    for q, qs := range samples {
+        mass := sampleMass(qs)
+        if mass == 0 {
             continue
         }
         w, ok := weights[q]
@@ -68,12 +70,12 @@ func CalculateFinalSentiment(
         // normalize this question’s weight
         norm := w / totalWeight
 
-        // compute the average score for this question
+        // compute the weighted average score for this question
         sum := 0.0
-        for _, s := range scores {
-            sum += s
+        for _, s := range qs {
+            sum += s.Score * s.Weight
         }
-        avg := sum / float64(len(scores))
+        avg := sum / mass
 
         final += norm * avg
     }
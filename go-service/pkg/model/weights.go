@@ -0,0 +1,124 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultWeights are the built-in per-question weights, keyed by question
+// code as seeded into the questions table. Once the question registry is
+// loaded they are re-keyed by question ID to form the default profile.
+var DefaultWeights = map[string]float64{
+	"1": 0.15, // New Features or Use Cases
+	"2": 0.10, // Founders or Leadership
+	"3": 0.20, // Security Concerns or Hacks
+	"4": 0.20, // Market Trends and Price Predictions
+	"5": 0.10, // Regulatory Updates and Government Policies
+	"6": 0.10, // Community Sentiment and Adoption
+	"7": 0.10, // Partnerships and Integrations
+	"8": 0.05, // Mining and Staking Discussions
+}
+
+// DefaultProfile names the profile used by coins without an assignment.
+const DefaultProfile = "default"
+
+// weightSumTolerance is how far a weight set may stray from summing to 1.
+const weightSumTolerance = 1e-6
+
+// WeightProfiles holds named per-question weight sets and which coin uses
+// which. Every configuration has a DefaultProfile.
+type WeightProfiles struct {
+	// Profiles maps a profile name to its weights by question ID.
+	Profiles map[string]map[string]float64 `json:"profiles"`
+	// Coins maps a coin code to the name of its profile.
+	Coins map[string]string `json:"coins"`
+}
+
+var activeProfiles atomic.Pointer[WeightProfiles]
+
+// BuiltinProfiles is the configuration in effect before any is loaded.
+func BuiltinProfiles() WeightProfiles {
+	return WeightProfiles{Profiles: map[string]map[string]float64{DefaultProfile: DefaultWeights}}
+}
+
+// Profiles returns the weight profiles in effect. The value is shared and
+// must not be modified; a run that reads it once sees one consistent
+// configuration even while SetProfiles swaps in another.
+func Profiles() WeightProfiles {
+	if p := activeProfiles.Load(); p != nil {
+		return *p
+	}
+	return BuiltinProfiles()
+}
+
+// SetProfiles atomically replaces the weight profiles in effect.
+func SetProfiles(p WeightProfiles) {
+	activeProfiles.Store(&p)
+}
+
+// Weights returns the weights of the default profile in effect.
+func Weights() map[string]float64 {
+	return Profiles().Profiles[DefaultProfile]
+}
+
+// For returns the profile assigned to the coin with the given code and its
+// weights, falling back to DefaultProfile.
+func (p WeightProfiles) For(code string) (string, map[string]float64) {
+	if name, ok := p.Coins[code]; ok {
+		if w, ok := p.Profiles[name]; ok {
+			return name, w
+		}
+	}
+	return DefaultProfile, p.Profiles[DefaultProfile]
+}
+
+// Validate checks every profile with ValidateWeights, requires a
+// DefaultProfile and rejects coin assignments to missing profiles.
+func (p WeightProfiles) Validate(questions map[string]string) error {
+	if _, ok := p.Profiles[DefaultProfile]; !ok {
+		return fmt.Errorf("no %q profile", DefaultProfile)
+	}
+	for name, w := range p.Profiles {
+		if err := ValidateWeights(w, questions); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	for code, name := range p.Coins {
+		if _, ok := p.Profiles[name]; !ok {
+			return fmt.Errorf("coin %s uses unknown profile %q", code, name)
+		}
+	}
+	return nil
+}
+
+// ValidateWeights rejects empty sets, negative weights, weights that do
+// not sum to 1 and, when questions is non-nil, IDs missing from it.
+func ValidateWeights(w map[string]float64, questions map[string]string) error {
+	if len(w) == 0 {
+		return fmt.Errorf("no weights")
+	}
+	var unknown []string
+	sum := 0.0
+	for q, v := range w {
+		if v < 0 || math.IsNaN(v) {
+			return fmt.Errorf("weight for question %s must not be negative", q)
+		}
+		if questions != nil {
+			if _, ok := questions[q]; !ok {
+				unknown = append(unknown, q)
+			}
+		}
+		sum += v
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown question IDs: %s", strings.Join(unknown, ", "))
+	}
+	if math.Abs(sum-1) > weightSumTolerance {
+		return fmt.Errorf("weights sum to %g, want 1", sum)
+	}
+	return nil
+}
@@ -2,24 +2,23 @@
 package openai
 
 import (
-    "log"
-    "os"
-
     "github.com/openai/openai-go"
     "github.com/openai/openai-go/option"
+
+    "github.com/cosmic-hash/CryptoPulse/pkg/config"
 )
 
 // ChatClient is the shared OpenAI client for your service.
 var ChatClient openai.Client
 
-// InitClient must be called after .env is loaded, so OPENAI_API_KEY is present.
-func InitClient() {
-    apiKey := os.Getenv("OPENAI_API_KEY")
-    if apiKey == "" {
-        log.Fatal("OPENAI_API_KEY is not set")
-    }
+// Model is the chat model requests should use.
+var Model string
+
+// InitClient must be called once the settings are loaded.
+func InitClient(s config.OpenAISettings) {
     // openai.NewClient returns an openai.Client (not *openai.Client)
     ChatClient = openai.NewClient(
-        option.WithAPIKey(apiKey),
+        option.WithAPIKey(s.APIKey),
     )
+    Model = s.Model
 }
\ No newline at end of file